@@ -0,0 +1,372 @@
+package client
+
+// Client is the typed client of the registry http api, see httpd/README.md.
+// Client send the request to the endpoints in order. The read request, GET or HEAD, is retried
+// with the next endpoint if it fail by network error or the endpoint is unavailable.
+// The write request is only retried if it is known not sent, e.g. fail to connect the endpoint,
+// because the write which reached the registry may be applied even if the response is lost.
+// The endpoint which served the last request is used first next time.
+// Redirect response, e.g. to the leader, is followed with the same method and body.
+// The write is only redirected to the endpoints or the host of the request, and the token is not sent
+// to the other host when the read is redirected.
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lodastack/registry/model"
+	"github.com/lodastack/registry/tree/node"
+)
+
+const (
+	defaultTimeout = 10 * time.Second
+	defaultBackoff = 100 * time.Millisecond
+	maxRedirects   = 10
+
+	tokenHeader = "AuthToken"
+)
+
+var (
+	// ErrNoEndpoint is returned if create client without endpoint.
+	ErrNoEndpoint = errors.New("no registry endpoint")
+
+	// ErrTooManyRedirects is returned if the request is redirected more than maxRedirects times.
+	ErrTooManyRedirects = errors.New("too many redirects")
+
+	// ErrUntrustedRedirect is returned if the write is redirected to the host which is not the endpoint,
+	// the token and body are not sent to it.
+	ErrUntrustedRedirect = errors.New("redirect to untrusted host")
+)
+
+// Error is returned if the registry response a non 2xx status.
+type Error struct {
+	Code int
+	Msg  string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("registry response %d: %s", e.Code, e.Msg)
+}
+
+// response is the body format of registry http api.
+// Error is set if the resources written as stream is broken by error.
+type response struct {
+	Code  int             `json:"httpstatus"`
+	Data  json.RawMessage `json:"data"`
+	Msg   string          `json:"msg"`
+	Error string          `json:"error"`
+}
+
+// bodyParam is the resource param in request body.
+type bodyParam struct {
+	Ns        string             `json:"ns"`
+	ResType   string             `json:"type"`
+	ResID     string             `json:"resourceid,omitempty"`
+	UpdateMap map[string]string  `json:"update,omitempty"`
+	Rl        model.ResourceList `json:"resourcelist,omitempty"`
+	R         model.Resource     `json:"resource,omitempty"`
+}
+
+// Client is the registry http api client.
+type Client struct {
+	endpoints  []string
+	token      string
+	retry      int
+	backoff    time.Duration
+	httpClient *http.Client
+
+	mu      sync.Mutex
+	current int
+}
+
+// Option config the client.
+type Option func(*Client)
+
+// WithToken set the AuthToken header of every request.
+func WithToken(token string) Option {
+	return func(c *Client) { c.token = token }
+}
+
+// WithRetry set the times to retry all endpoints and the backoff between retry.
+func WithRetry(retry int, backoff time.Duration) Option {
+	return func(c *Client) { c.retry, c.backoff = retry, backoff }
+}
+
+// WithHTTPClient set the http client used to send request.
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) { c.httpClient = httpClient }
+}
+
+// New return the client of the registry endpoints, e.g. "127.0.0.1:8000" or "https://registry.test.com".
+func New(endpoints []string, opts ...Option) (*Client, error) {
+	if len(endpoints) == 0 {
+		return nil, ErrNoEndpoint
+	}
+	c := &Client{
+		backoff:    defaultBackoff,
+		httpClient: &http.Client{Timeout: defaultTimeout},
+	}
+	for _, endpoint := range endpoints {
+		if !strings.HasPrefix(endpoint, "http://") && !strings.HasPrefix(endpoint, "https://") {
+			endpoint = "http://" + endpoint
+		}
+		c.endpoints = append(c.endpoints, strings.TrimRight(endpoint, "/"))
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c, nil
+}
+
+// unavailable return whether the status means the endpoint could not serve now.
+func unavailable(code int) bool {
+	return code == http.StatusBadGateway || code == http.StatusServiceUnavailable || code == http.StatusGatewayTimeout
+}
+
+// safeMethod return whether the request of method only read, so it could be sent again.
+func safeMethod(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead
+}
+
+// notSent return whether the request fail before sent to the endpoint, e.g. connection refused.
+func notSent(err error) bool {
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
+
+// isRedirect return whether the status is a redirect with Location.
+func isRedirect(code int) bool {
+	switch code {
+	case http.StatusMovedPermanently, http.StatusFound, http.StatusSeeOther,
+		http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+		return true
+	}
+	return false
+}
+
+// trusted return whether the token could be sent to u, which is on the host of origin or any endpoint.
+func (c *Client) trusted(u, origin *url.URL) bool {
+	if u.Host == origin.Host {
+		return true
+	}
+	for _, endpoint := range c.endpoints {
+		if e, err := url.Parse(endpoint); err == nil && e.Host == u.Host {
+			return true
+		}
+	}
+	return false
+}
+
+// do send the request and unmarshal the data of response to out if out is not nil.
+// The request is sent to the next endpoint only if it is safe to send again, see Client.
+func (c *Client) do(method, path string, query url.Values, body interface{}, out interface{}) error {
+	var reqBody []byte
+	if body != nil {
+		var err error
+		if reqBody, err = json.Marshal(body); err != nil {
+			return err
+		}
+	}
+
+	c.mu.Lock()
+	start := c.current
+	c.mu.Unlock()
+
+	var lastErr error
+	for attempt := 0; attempt <= c.retry; attempt++ {
+		if attempt > 0 {
+			time.Sleep(c.backoff)
+		}
+		for i := range c.endpoints {
+			index := (start + i) % len(c.endpoints)
+			resp, err := c.send(c.endpoints[index], method, path, query, reqBody)
+			if err != nil {
+				if !safeMethod(method) && !notSent(err) {
+					return err
+				}
+				lastErr = err
+				continue
+			}
+			if unavailable(resp.Code) {
+				lastErr = &Error{Code: resp.Code, Msg: resp.Msg}
+				if !safeMethod(method) {
+					return lastErr
+				}
+				continue
+			}
+
+			c.mu.Lock()
+			c.current = index
+			c.mu.Unlock()
+			if resp.Code < 200 || resp.Code >= 300 {
+				return &Error{Code: resp.Code, Msg: resp.Msg}
+			}
+			if out == nil || len(resp.Data) == 0 {
+				return nil
+			}
+			return json.Unmarshal(resp.Data, out)
+		}
+	}
+	return lastErr
+}
+
+// send send the request to the endpoint and follow the redirect.
+// net/http change the POST to GET when follow 301/302/303, so the redirect of the write
+// request is followed here with the same method and body, only to the trusted host.
+// The read is redirected by net/http, which keep the custom header, so the token is removed
+// if it is redirected to the untrusted host.
+func (c *Client) send(endpoint, method, path string, query url.Values, body []byte) (*response, error) {
+	u := endpoint + path
+	if len(query) != 0 {
+		u += "?" + query.Encode()
+	}
+	origin, err := url.Parse(u)
+	if err != nil {
+		return nil, err
+	}
+	redirectClient := *c.httpClient
+	if safeMethod(method) {
+		checkRedirect := c.httpClient.CheckRedirect
+		redirectClient.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+			if len(via) >= maxRedirects {
+				return ErrTooManyRedirects
+			}
+			if !c.trusted(req.URL, via[0].URL) {
+				req.Header.Del(tokenHeader)
+			}
+			if checkRedirect != nil {
+				return checkRedirect(req, via)
+			}
+			return nil
+		}
+	} else {
+		redirectClient.CheckRedirect = func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }
+	}
+	httpClient := &redirectClient
+
+	for redirects := 0; ; redirects++ {
+		req, err := http.NewRequest(method, u, bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		if c.token != "" {
+			req.Header.Set(tokenHeader, c.token)
+		}
+		if body != nil {
+			req.Header.Set("Content-Type", "application/json")
+		}
+
+		httpResp, err := httpClient.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		if !safeMethod(method) && isRedirect(httpResp.StatusCode) {
+			location, err := httpResp.Location()
+			httpResp.Body.Close()
+			if err != nil {
+				return nil, err
+			}
+			if redirects >= maxRedirects {
+				return nil, ErrTooManyRedirects
+			}
+			if !c.trusted(location, origin) {
+				return nil, ErrUntrustedRedirect
+			}
+			u = location.String()
+			continue
+		}
+		defer httpResp.Body.Close()
+		data, err := ioutil.ReadAll(httpResp.Body)
+		if err != nil {
+			return nil, err
+		}
+
+		resp := &response{}
+		if err := json.Unmarshal(data, resp); err != nil || resp.Code == 0 {
+			resp.Code, resp.Msg = httpResp.StatusCode, string(data)
+		} else if resp.Error != "" {
+			resp.Code, resp.Msg = http.StatusInternalServerError, resp.Error
+		}
+		return resp, nil
+	}
+}
+
+// GetNs return the node of the ns, return the whole tree if ns is empty.
+func (c *Client) GetNs(ns string) (*node.Node, error) {
+	n := &node.Node{}
+	err := c.do(http.MethodGet, "/api/v1/ns", url.Values{"ns": {ns}}, nil, n)
+	return n, err
+}
+
+// GetResourceList return the resource list of the ns.
+func (c *Client) GetResourceList(ns, resType string) (model.ResourceList, error) {
+	var rl model.ResourceList
+	err := c.do(http.MethodGet, "/api/v1/resource", url.Values{"ns": {ns}, "type": {resType}}, nil, &rl)
+	return rl, err
+}
+
+// SetResource set the resource list to the ns.
+func (c *Client) SetResource(ns, resType string, rl model.ResourceList) error {
+	return c.do(http.MethodPost, "/api/v1/resource", nil, bodyParam{Ns: ns, ResType: resType, Rl: rl}, nil)
+}
+
+// AppendResource append a resource to the ns.
+func (c *Client) AppendResource(ns, resType string, r model.Resource) error {
+	return c.do(http.MethodPost, "/api/v1/resource/add", nil, bodyParam{Ns: ns, ResType: resType, R: r}, nil)
+}
+
+// UpdateResource update the resource by updateMap.
+func (c *Client) UpdateResource(ns, resType, resID string, updateMap map[string]string) error {
+	return c.do(http.MethodPut, "/api/v1/resource", nil,
+		bodyParam{Ns: ns, ResType: resType, ResID: resID, UpdateMap: updateMap}, nil)
+}
+
+// RemoveResource remove the resources from the ns.
+func (c *Client) RemoveResource(ns, resType string, resIDs ...string) error {
+	return c.do(http.MethodDelete, "/api/v1/resource",
+		url.Values{"ns": {ns}, "type": {resType}, "resourceid": {strings.Join(resIDs, ",")}}, nil, nil)
+}
+
+// SearchResource search the resource in the ns and its child ns, return the ns-resource list map.
+func (c *Client) SearchResource(ns, resType, k, v string, fuzzy bool) (map[string]model.ResourceList, error) {
+	query := url.Values{"ns": {ns}, "type": {resType}, "k": {k}, "v": {v}}
+	if fuzzy {
+		query.Set("mod", "fuzzy")
+	}
+	result := map[string]model.ResourceList{}
+	err := c.do(http.MethodGet, "/api/v1/resource/search", query, nil, &result)
+	return result, err
+}
+
+// RegisterMachine register the machine to the ns which match its hostname, return the ns-resource ID map.
+func (c *Client) RegisterMachine(machine model.Resource) (map[string]string, error) {
+	result := map[string]string{}
+	err := c.do(http.MethodPost, "/api/v1/agent/ns", nil, machine, &result)
+	return result, err
+}
+
+// GetDashboard return the dashboards of the ns.
+func (c *Client) GetDashboard(ns string) (model.DashboardData, error) {
+	var dashboards model.DashboardData
+	err := c.do(http.MethodGet, "/api/v1/dashboard", url.Values{"ns": {ns}}, nil, &dashboards)
+	return dashboards, err
+}
+
+// SetDashboard set the dashboards to the ns.
+func (c *Client) SetDashboard(ns string, dashboards model.DashboardData) error {
+	return c.do(http.MethodPost, "/api/v1/dashboard", url.Values{"ns": {ns}}, dashboards, nil)
+}
+
+// AddDashboard add a dashboard to the ns.
+func (c *Client) AddDashboard(ns string, dashboard model.Dashboard) error {
+	return c.do(http.MethodPost, "/api/v1/dashboard/add", url.Values{"ns": {ns}}, dashboard, nil)
+}
@@ -0,0 +1,181 @@
+package client
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/lodastack/registry/config"
+	"github.com/lodastack/registry/httpd"
+	"github.com/lodastack/registry/model"
+	"github.com/lodastack/registry/tree/test_sample"
+
+	sm "github.com/lodastack/store/model"
+	"github.com/lodastack/store/store"
+)
+
+// testCluster is the mock store with the cluster methods httpd need but the test not use.
+type testCluster struct {
+	*store.Store
+}
+
+func (c testCluster) Join(addr string) error                           { return nil }
+func (c testCluster) Remove(addr string) error                         { return nil }
+func (c testCluster) RemoveKey(bucket, key []byte) error               { return c.Update(bucket, key, nil) }
+func (c testCluster) GetSession(key interface{}) interface{}           { return nil }
+func (c testCluster) SetSession(key, value interface{}) error          { return nil }
+func (c testCluster) DelSession(key interface{}) error                 { return nil }
+func (c testCluster) Backup() ([]byte, error)                          { return nil, nil }
+func (c testCluster) Restore(backupfile string) error                  { return nil }
+func (c testCluster) Peers() (map[string]map[string]string, error)     { return nil, nil }
+func (c testCluster) Statistics(tags map[string]string) []sm.Statistic { return nil }
+
+// newTestServer start the registry http service on the mock store,
+// return the server and the func to close it.
+func newTestServer(t *testing.T) (*httptest.Server, func()) {
+	s := test_sample.MustNewStore(t)
+	if err := s.Open(true); err != nil {
+		t.Fatalf("failed to open single-node store: %s", err.Error())
+	}
+	s.WaitForLeader(10 * time.Second)
+	service, err := httpd.New(config.HTTPConfig{}, testCluster{s})
+	if err != nil {
+		t.Fatalf("new http service fail: %s", err.Error())
+	}
+	server := httptest.NewServer(service.Handler())
+	return server, func() {
+		server.Close()
+		s.Close(true)
+		os.RemoveAll(s.Path())
+	}
+}
+
+func TestClientRequest(t *testing.T) {
+	server, closeFn := newTestServer(t)
+	defer closeFn()
+
+	c, err := New([]string{server.URL}, WithToken("token"))
+	if err != nil {
+		t.Fatalf("new client fail: %s", err.Error())
+	}
+	regMap, err := c.RegisterMachine(model.Resource{"hostname": "host1"})
+	if err != nil || regMap["pool.loda"] == "" {
+		t.Fatalf("register machine not match with expect: %+v, %v", regMap, err)
+	}
+	rl, err := c.GetResourceList("pool.loda", "machine")
+	if err != nil || len(rl) != 1 {
+		t.Fatalf("get resource list not match with expect: %+v, %v", rl, err)
+	}
+
+	if err := c.AddDashboard("pool.loda", model.Dashboard{Title: "d0"}); err != nil {
+		t.Fatalf("add dashboard fail: %s", err.Error())
+	}
+	dashboards, err := c.GetDashboard("pool.loda")
+	if err != nil || len(dashboards) != 1 || dashboards[0].Title != "d0" {
+		t.Fatalf("get dashboard not match with expect: %+v, %v", dashboards, err)
+	}
+	if _, err = c.GetDashboard("notexist.loda"); err == nil {
+		t.Fatal("get dashboard of not exist ns success, not match with expect")
+	} else if _, ok := err.(*Error); !ok {
+		t.Fatalf("get dashboard of not exist ns not match with expect: %v", err)
+	}
+}
+
+func TestClientFailover(t *testing.T) {
+	server, closeFn := newTestServer(t)
+	defer closeFn()
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer down.Close()
+
+	// case 1: the read is sent to the next endpoint.
+	c, _ := New([]string{down.URL, "127.0.0.1:1", server.URL})
+	if _, err := c.GetDashboard("pool.loda"); err != nil {
+		t.Fatalf("request with failover fail: %s", err.Error())
+	}
+	if c.current != 2 {
+		t.Fatalf("client not remember the available endpoint: %d", c.current)
+	}
+
+	// case 2: the write reached an endpoint is not sent again.
+	c, _ = New([]string{down.URL, server.URL})
+	if err := c.AddDashboard("pool.loda", model.Dashboard{Title: "d0"}); err == nil {
+		t.Fatal("write to unavailable endpoint success, not match with expect")
+	} else if e, ok := err.(*Error); !ok || e.Code != http.StatusServiceUnavailable {
+		t.Fatalf("write to unavailable endpoint not match with expect: %v", err)
+	}
+	var dropCount int32
+	drop := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&dropCount, 1)
+		conn, _, _ := w.(http.Hijacker).Hijack()
+		conn.Close()
+	}))
+	defer drop.Close()
+	c, _ = New([]string{drop.URL, server.URL}, WithRetry(2, time.Millisecond))
+	if err := c.AddDashboard("pool.loda", model.Dashboard{Title: "d0"}); err == nil {
+		t.Fatal("write to dropped endpoint success, not match with expect")
+	}
+	if count := atomic.LoadInt32(&dropCount); count != 1 {
+		t.Fatalf("write is sent %d times, not match with expect", count)
+	}
+	if dashboards, _ := c.GetDashboard("pool.loda"); len(dashboards) != 0 {
+		t.Fatalf("write is sent to the next endpoint: %+v", dashboards)
+	}
+
+	// case 3: the write not sent is sent to the next endpoint.
+	c, _ = New([]string{"127.0.0.1:1", server.URL})
+	if err := c.AddDashboard("pool.loda", model.Dashboard{Title: "d0"}); err != nil {
+		t.Fatalf("write with failover fail: %s", err.Error())
+	}
+
+	// case 4: follow the redirect, e.g. to the leader, with the same method and body.
+	for _, code := range []int{http.StatusFound, http.StatusTemporaryRedirect} {
+		redirect := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			http.Redirect(w, r, server.URL+r.URL.RequestURI(), code)
+		}))
+		c, _ = New([]string{redirect.URL, server.URL})
+		if err := c.AddDashboard("pool.loda", model.Dashboard{Title: "redirect"}); err != nil {
+			t.Fatalf("write with redirect %d fail: %s", code, err.Error())
+		}
+		if _, err := c.GetDashboard("pool.loda"); err != nil {
+			t.Fatalf("read with redirect %d fail: %s", code, err.Error())
+		}
+		redirect.Close()
+	}
+	c, _ = New([]string{server.URL})
+	if dashboards, err := c.GetDashboard("pool.loda"); err != nil || len(dashboards) != 3 {
+		t.Fatalf("dashboards written with redirect not match with expect: %+v, %v", dashboards, err)
+	}
+
+	// case 5: the write is not redirected to the host which is not the endpoint,
+	// and the token is not sent to it when the read is redirected.
+	var untrustedToken atomic.Value
+	untrustedToken.Store("")
+	untrusted := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		untrustedToken.Store(r.Header.Get(tokenHeader))
+		w.Write([]byte(`{"httpstatus":200,"data":[]}`))
+	}))
+	defer untrusted.Close()
+	redirect := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, untrusted.URL+r.URL.RequestURI(), http.StatusTemporaryRedirect)
+	}))
+	defer redirect.Close()
+	c, _ = New([]string{redirect.URL}, WithToken("token"))
+	if err := c.AddDashboard("pool.loda", model.Dashboard{Title: "untrusted"}); err != ErrUntrustedRedirect {
+		t.Fatalf("write redirected to untrusted host not match with expect: %v", err)
+	}
+	if _, err := c.GetDashboard("pool.loda"); err != nil {
+		t.Fatalf("read with redirect to untrusted host fail: %s", err.Error())
+	}
+	if token := untrustedToken.Load().(string); token != "" {
+		t.Fatalf("token is sent to untrusted host: %s", token)
+	}
+
+	if _, err := New(nil); err != ErrNoEndpoint {
+		t.Fatalf("new client without endpoint not match with expect: %v", err)
+	}
+}
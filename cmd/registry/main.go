@@ -50,13 +50,13 @@ func init() {
 
 // Main represents the program execution.
 type Main struct {
-	logger *log.Logger
+	logger *model.Logger
 }
 
 // NewMain return a new instance of Main.
 func NewMain() *Main {
 	return &Main{
-		logger: log.New(config.C.LogConf.Level, "main", model.LogBackend),
+		logger: model.NewLogger(config.C.LogConf.Level, "main"),
 	}
 }
 
@@ -101,6 +101,7 @@ func (m *Main) Start() error {
 	// store config
 	c := config.C.DataConf
 
+	// the store keep the *log.Logger, its level could not be changed at runtime.
 	storeLogger := log.New(config.C.LogConf.Level, "store", model.LogBackend)
 	opts := cluster.Options{
 		Bind:     c.ClusterBind,
@@ -150,6 +151,8 @@ func (m *Main) Start() error {
 	if err := h.Start(); err != nil {
 		return fmt.Errorf("failed to start HTTP service: %v", err)
 	}
+	// purge the expired tombstone and archive on the leader only.
+	h.RunPurge(cs.IsLeader)
 
 	// DNS service
 	dns, err := dns.New(config.C.DNSConf, cs)
@@ -157,12 +160,18 @@ func (m *Main) Start() error {
 		return fmt.Errorf("failed to start DNS service: %v", err)
 	}
 
+	stopRejoin := make(chan struct{})
+	if joinAddr != "" && c.AllowRejoin {
+		go m.rejoin(cs, joinAddr, c.ClusterBind, stopRejoin)
+	}
+
 	m.logger.Printf("registry started successfully")
 
 	terminate := make(chan os.Signal, 1)
 	signal.Notify(terminate, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP, syscall.SIGKILL)
 	<-terminate
 	stopProfile()
+	close(stopRejoin)
 
 	// close DNS service
 	if err := dns.Close(); err != nil {
@@ -174,8 +183,12 @@ func (m *Main) Start() error {
 		m.logger.Errorf("close HTTP failed: %v", err)
 	}
 
-	// close cluster service
-	if err := cs.Close(); err != nil {
+	// close cluster service, do not wait the stuck shutdown forever,
+	// the stuck close is left running and stopped by the process exit.
+	closeTimeout := time.Duration(c.CloseTimeout) * time.Second
+	if err := closeWithTimeout(cs.Close, closeTimeout); err == ErrShutdownTimeout {
+		m.logger.Errorf("close cluster service not finished in %s, exit anyway", closeTimeout)
+	} else if err != nil {
 		m.logger.Errorf("close cluster service failed: %v", err)
 	}
 
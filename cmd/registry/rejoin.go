@@ -0,0 +1,53 @@
+package main
+
+import (
+	"time"
+)
+
+const (
+	rejoinCheckInterval = 30 * time.Second
+	rejoinMinBackoff    = 10 * time.Second
+	rejoinMaxBackoff    = 5 * time.Minute
+)
+
+// rejoinCluster is the cluster service used to rejoin.
+type rejoinCluster interface {
+	Peers() (map[string]map[string]string, error)
+	JoinCluster(joinAddr, raftAddr string) error
+}
+
+// rejoin check whether the node is still in the peers of the cluster,
+// and join the cluster again by joinAddr if it is removed.
+// The failed join is retried with backoff, from rejoinMinBackoff up to rejoinMaxBackoff.
+func (m *Main) rejoin(cs rejoinCluster, joinAddr, raftAddr string, stop <-chan struct{}) {
+	wait, backoff := rejoinCheckInterval, rejoinMinBackoff
+	for {
+		select {
+		case <-stop:
+			return
+		case <-time.After(wait):
+		}
+
+		wait = rejoinCheckInterval
+		peers, err := cs.Peers()
+		if err != nil {
+			m.logger.Errorf("get peers fail: %v", err)
+			continue
+		}
+		if _, ok := peers[raftAddr]; ok {
+			backoff = rejoinMinBackoff
+			continue
+		}
+
+		m.logger.Warningf("node %s is not in the peers of cluster, rejoin by %s", raftAddr, joinAddr)
+		if err := cs.JoinCluster(joinAddr, raftAddr); err != nil {
+			m.logger.Errorf("rejoin cluster by %s fail, retry after %s: %v", joinAddr, backoff, err)
+			wait = backoff
+			if backoff *= 2; backoff > rejoinMaxBackoff {
+				backoff = rejoinMaxBackoff
+			}
+			continue
+		}
+		m.logger.Printf("node %s rejoined the cluster", raftAddr)
+	}
+}
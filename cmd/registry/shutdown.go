@@ -0,0 +1,27 @@
+package main
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrShutdownTimeout is returned if the service is not closed before the timeout.
+var ErrShutdownTimeout = errors.New("shutdown timeout")
+
+// closeWithTimeout call closeFn and wait it return at most timeout, wait forever if timeout is 0.
+// Return ErrShutdownTimeout if closeFn not return in time, closeFn keep running in background then.
+// NOTE: the stuck close is not force closed, the store has no method to abort its close,
+// e.g. the raft shutdown or bolt close. It is only stopped when the process exit.
+func closeWithTimeout(closeFn func() error, timeout time.Duration) error {
+	if timeout <= 0 {
+		return closeFn()
+	}
+	done := make(chan error, 1)
+	go func() { done <- closeFn() }()
+	select {
+	case err := <-done:
+		return err
+	case <-time.After(timeout):
+		return ErrShutdownTimeout
+	}
+}
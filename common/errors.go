@@ -9,17 +9,19 @@ var (
 	ErrNodeNotFound        = errors.New("node not found")
 	ErrGetParent           = errors.New("get parent node error")
 	ErrCreateNodeUnderLeaf = errors.New("can not create node under leaf node")
-	ErrSetResourceToLeaf   = errors.New("can not set resource to leaf node")
 	ErrGetNodeID           = errors.New("get nodeid fail")
 	ErrInvalidParam        = errors.New("invalid param")
 	ErrNilChildNode        = errors.New("get none child node")
 	ErrNodeAlreadyExist    = errors.New("node already exist")
 	ErrNoLeafChild         = errors.New("have no leaf child node")
 	ErrNotAllowDel         = errors.New("not allow to be delete")
+	ErrWrongNodeType       = errors.New("wrong node type")
 
 	ErrEmptyResource error = errors.New("empty resources")
 
 	ErrGroupNotFound     = errors.New("group not found")
 	ErrGroupAlreadyExist = errors.New("group already exist")
 	ErrUserNotFound      = errors.New("user not found")
+	ErrMachineNotFound   = errors.New("machine not found")
+	ErrQuotaExceeded     = errors.New("resource quota exceeded")
 )
@@ -51,11 +51,19 @@ type EventConfig struct {
 }
 
 type CommonConfig struct {
-	Admins          []string `toml:"admins"`
-	RouterAddr      string   `toml:"routeraddr"`
-	PersistReport   int      `toml:"persistreport"`
-	PID             string   `toml:"pid"`
-	ProductionUsers []string `toml:"productionusers"`
+	Admins             []string `toml:"admins"`
+	RouterAddr         string   `toml:"routeraddr"`
+	PersistReport      int      `toml:"persistreport"`
+	PID                string   `toml:"pid"`
+	ProductionUsers    []string `toml:"productionusers"`
+	Codec              string   `toml:"codec"`
+	ResourceCache      int      `toml:"resourcecache"`
+	HealthStale        int      `toml:"healthstale"`
+	HealthDown         int      `toml:"healthdown"`
+	ArchiveRetention   int      `toml:"archiveretention"`
+	TombstoneRetention int      `toml:"tombstoneretention"`
+	Indexes            []string `toml:"indexes"`
+	DashboardPageLimit int      `toml:"dashboardpagelimit"`
 }
 
 type HTTPConfig struct {
@@ -69,16 +77,20 @@ type DataConfig struct {
 	Dir           string `toml:"dir"`
 	ClusterBind   string `toml:"clusterbind"`
 	ClusterLeader string `toml:"clusterleader"`
+	AllowRejoin   bool   `toml:"allowrejoin"`
+	CloseTimeout  int    `toml:"closetimeout"`
 }
 
 // LDAPConfig is LDAP config struct
 type LDAPConfig struct {
-	Enable   bool   `toml:"enable"`
-	Server   string `toml:"server"`
-	UID      string `toml:"uid"`
-	Binddn   string `toml:"binddn"`
-	Password string `toml:"password"`
-	Base     string `toml:"base"`
+	Enable    bool   `toml:"enable"`
+	Server    string `toml:"server"`
+	UID       string `toml:"uid"`
+	Binddn    string `toml:"binddn"`
+	Password  string `toml:"password"`
+	Base      string `toml:"base"`
+	GroupAttr string `toml:"groupattr"`
+	GroupTTL  int    `toml:"groupttl"`
 }
 
 // WeworkConfig is wework config struct
@@ -102,7 +102,7 @@ type Service struct {
 
 	tree tree.TreeMethod
 
-	logger *log.Logger
+	logger *model.Logger
 }
 
 // New DNS service
@@ -120,7 +120,7 @@ func New(c config.DNSConfig, cluster httpd.Cluster) (*Service, error) {
 		cache:  make(map[string][]dnslib.RR),
 		tree:   tree,
 
-		logger: log.New("INFO", "dns", model.LogBackend),
+		logger: model.NewLogger("INFO", "dns"),
 	}, nil
 }
 
@@ -22,6 +22,7 @@ import (
 	"github.com/lodastack/registry/model"
 	"github.com/lodastack/registry/tree"
 	"github.com/lodastack/registry/tree/node"
+	"github.com/lodastack/registry/tree/resource"
 	"github.com/lodastack/registry/utils"
 
 	"github.com/julienschmidt/httprouter"
@@ -96,7 +97,13 @@ type Service struct {
 	tree    tree.TreeMethod
 	perm    authorize.Perm
 
-	logger *log.Logger
+	// groups cache the ldap groups of the session user.
+	groups *groupCache
+
+	// stopPurge stop the purge started by RunPurge.
+	stopPurge func()
+
+	logger *model.Logger
 }
 
 type bodyParam struct {
@@ -134,21 +141,25 @@ func New(c config.HTTPConfig, cluster Cluster) (*Service, error) {
 		cluster: cluster,
 		tree:    tree,
 		perm:    perm,
+		groups:  newGroupCache(time.Duration(config.C.LDAPConf.GroupTTL) * time.Second),
 		router:  httprouter.New(),
-		logger:  log.New("INFO", "http", model.LogBackend),
+		logger:  model.NewLogger("INFO", "http"),
 	}, nil
 }
 
-// Start the server
-func (s *Service) Start() error {
+// Handler init the api handlers and return the http handler of the service,
+// Start serve it on the listener. It should be called only once.
+func (s *Service) Handler() http.Handler {
 	s.initHandler()
-
-	server := http.Server{}
 	if config.C.LDAPConf.Enable {
-		server.Handler = s.accessLog(cors(s.auth(s.router)))
-	} else {
-		server.Handler = s.accessLog(cors(s.router))
+		return s.accessLog(cors(s.auth(s.router)))
 	}
+	return s.accessLog(cors(s.router))
+}
+
+// Start the server
+func (s *Service) Start() error {
+	server := http.Server{Handler: s.Handler()}
 
 	// Open listener.
 	if s.https {
@@ -189,10 +200,19 @@ func (s *Service) Start() error {
 
 // Close closes the service.
 func (s *Service) Close() error {
+	if s.stopPurge != nil {
+		s.stopPurge()
+	}
 	s.ln.Close()
 	return nil
 }
 
+// RunPurge purge the expired tombstone and archive periodically when isLeader return true,
+// it is stopped when the service is closed.
+func (s *Service) RunPurge(isLeader func() bool) {
+	s.stopPurge = s.tree.RunPurge(isLeader)
+}
+
 // NormalizeAddr ensures that the given URL has a HTTP protocol prefix.
 // If none is supplied, it prefixes the URL with "http://".
 func NormalizeAddr(addr string) string {
@@ -214,12 +234,22 @@ func (s *Service) FormRedirect(r *http.Request, host string) string {
 func (s *Service) initHandler() {
 	s.router.POST("/api/v1/resource", s.handlerResourceSet)
 	s.router.POST("/api/v1/resource/add", s.handlerResourceAdd)
+	s.router.POST("/api/v1/resource/append", s.handlerResourceAppend)
 	s.router.GET("/api/v1/resource", s.handlerResourceGet)
 	s.router.GET("/api/v1/resource/search", s.handlerSearch)
+	s.router.GET("/api/v1/resource/types", s.handlerResourceTypes)
+	s.router.GET("/api/v1/resource/count", s.handlerResourceCount)
+	s.router.GET("/api/v1/resource/index", s.handlerResourceByIndex)
+	s.router.GET("/api/v1/resource/defaults", s.handlerResourceDefaultsGet)
+	s.router.PUT("/api/v1/resource/defaults", s.handlerResourceDefaultsSet)
 	s.router.PUT("/api/v1/resource", s.handleResourcePut)
 	s.router.PUT("/api/v1/resource/list", s.handleUpdateResourceList)
 	s.router.PUT("/api/v1/resource/move", s.handleResourceMove)
 	s.router.PUT("/api/v1/resource/copy", s.handleResourceCopy)
+	s.router.PUT("/api/v1/resource/rekey", s.handleResourceRekey)
+	s.router.PUT("/api/v1/resource/restore", s.handleResourceRestore)
+	s.router.PUT("/api/v1/resource/touch", s.handleResourceTouch)
+	s.router.GET("/api/v1/resource/touch", s.handleResourceTouchTime)
 	s.router.DELETE("/api/v1/resource", s.handleResourceDel)
 	s.router.DELETE("/api/v1/resource/list", s.handleRemoveResourceList)
 	s.router.DELETE("/api/v1/resource/collect", s.handleCollectDel)
@@ -228,8 +258,11 @@ func (s *Service) initHandler() {
 	s.router.PUT("/api/v1/ns", s.handlerNsUpdate)
 	s.router.GET("/api/v1/ns", s.handlerNsGet)
 	s.router.DELETE("/api/v1/ns", s.handlerNsDel)
+	s.router.GET("/api/v1/ns/hash", s.handlerNsHash)
+	s.router.GET("/api/v1/metrics", s.handlerMetrics)
 
 	s.router.GET("/api/v1/agents", s.handlerAgents)
+	s.router.GET("/api/v1/agents/health", s.handlerAgentsHealth)
 	s.router.GET("/api/v1/agent", s.handlerAgent)
 
 	// For agent
@@ -237,6 +270,14 @@ func (s *Service) initHandler() {
 	s.router.GET("/api/v1/agent/resource", s.handlerResourceGet)
 	s.router.POST("/api/v1/agent/report", s.handlerAgentReport)
 
+	s.router.POST("/api/v1/machine/decommission", s.handlerMachineDecommission)
+	s.router.GET("/api/v1/machine/archive", s.handlerMachineArchive)
+	s.router.GET("/api/v1/machine/search", s.handlerMachineSearch)
+	s.router.GET("/api/v1/machine/ns", s.handlerMachineNs)
+
+	s.router.GET("/api/v1/quota", s.handlerQuotaGet)
+	s.router.PUT("/api/v1/quota", s.handlerQuotaSet)
+
 	// For router, just allow Get method
 	s.router.GET("/api/v1/router/ns", s.handlerNsGet)
 	s.router.GET("/api/v1/router/resource", s.handlerResourceGet)
@@ -398,6 +439,7 @@ func uriFilter(r *http.Request) bool {
 
 // Handle handlerRegister search hostname on the tree first,
 // and register it if the machine not on the tree.
+// The machine is rejected with all the problems found if validate is true.
 func (s *Service) handlerRegister(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
 	buf := new(bytes.Buffer)
 	if _, err := buf.ReadFrom(r.Body); err != nil {
@@ -434,6 +476,19 @@ func (s *Service) handlerRegister(w http.ResponseWriter, r *http.Request, _ http
 	if status, _ := machine.ReadProperty(model.HostStatusProp); status == "" {
 		machine.SetProperty(model.HostStatusProp, "online")
 	}
+
+	// reject the machine with all the problems found if validate is true.
+	if r.FormValue("validate") == "true" {
+		errs, err := s.tree.ValidateMachines(machine)
+		if err != nil {
+			ReturnServerError(w, err)
+			return
+		}
+		if len(errs) != 0 {
+			ReturnJson(w, http.StatusBadRequest, errs)
+			return
+		}
+	}
 	regMap, err := s.tree.RegisterMachine(machine)
 	if err != nil {
 		s.logger.Errorf("RegisterMachine fail, error: %s", err.Error())
@@ -508,6 +563,15 @@ func (s *Service) handleResourceMove(w http.ResponseWriter, r *http.Request, ps
 	toNs := r.FormValue("to")
 	resType := r.FormValue("type")
 	resId := r.FormValue("resourceid")
+	if r.FormValue("keepid") == "true" {
+		refs, err := s.tree.MoveResourceWithRefs(fromNs, toNs, resType, strings.Split(resId, ",")...)
+		if err != nil {
+			returnResourceError(w, err)
+			return
+		}
+		ReturnJson(w, 200, refs)
+		return
+	}
 	if err := s.tree.MoveResource(fromNs, toNs, resType, strings.Split(resId, ",")...); err != nil {
 		ReturnServerError(w, err)
 		return
@@ -535,7 +599,9 @@ func (s *Service) handlerResourceSet(w http.ResponseWriter, r *http.Request, _ h
 		return
 	}
 
-	if err != nil {
+	if err == common.ErrQuotaExceeded {
+		ReturnForbidden(w, err.Error())
+	} else if err != nil {
 		ReturnServerError(w, err)
 	} else {
 		ReturnOK(w, "success")
@@ -548,19 +614,164 @@ func (s *Service) handlerResourceGet(w http.ResponseWriter, r *http.Request, _ h
 	ns := r.FormValue("ns")
 	resType := r.FormValue("type")
 
-	if ns != "" {
-		resList, err = s.tree.GetResourceList(ns, resType)
-	} else {
+	if ns == "" {
 		ReturnBadRequest(w, ErrInvalidParam)
 		return
 	}
-	if err != nil {
+	// only return the fields if provided.
+	if fields := r.FormValue("fields"); fields != "" {
+		projected, err := s.tree.GetResourceProjected(ns, resType, strings.Split(fields, ","))
+		if err != nil {
+			returnResourceError(w, err)
+			return
+		}
+		ReturnJson(w, 200, projected)
+		return
+	}
+
+	// write the resources as stream while they are unmarshaled from store, the resource list is not built.
+	if r.FormValue("stream") == "true" && r.FormValue("includedeleted") != "true" {
+		if _, err := s.tree.GetNodeByNS(ns); err != nil {
+			ReturnServerError(w, err)
+			return
+		}
+		// the error after some resources are written is returned in the error field of the body.
+		err := ReturnResourceStream(w, 200, func(fn func(model.Resource) error) error {
+			return s.tree.WalkResource(ns, resType, fn)
+		})
+		if err != nil {
+			s.logger.Errorf("write resource stream of ns %s fail: %s", ns, err.Error())
+		}
+		return
+	}
+
+	// also return the soft removed resources with the delete time.
+	if r.FormValue("includedeleted") == "true" {
+		all, err := s.tree.GetResourceIncludeDeleted(ns, resType)
+		if err != nil {
+			ReturnServerError(w, err)
+			return
+		}
+		rl := model.ResourceList(all)
+		resList = &rl
+	} else if resList, err = s.tree.GetResourceList(ns, resType); err != nil {
 		ReturnServerError(w, err)
 		return
 	}
+	// write large resource list as stream, so the response body is not built in memory.
+	if resList != nil && (len(*resList) > streamThreshold || r.FormValue("stream") == "true") {
+		if err := ReturnResourceStream(w, 200, walkList(*resList)); err != nil {
+			s.logger.Errorf("write resource stream of ns %s fail: %s", ns, err.Error())
+		}
+		return
+	}
 	ReturnJson(w, 200, resList)
 }
 
+func (s *Service) handleResourceRekey(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	ns := r.FormValue("ns")
+	resType := r.FormValue("type")
+	oldID := r.FormValue("oldid")
+	newID := r.FormValue("newid")
+	if ns == "" || resType == "" || oldID == "" || newID == "" {
+		ReturnBadRequest(w, ErrInvalidParam)
+		return
+	}
+	if err := s.tree.RekeyResource(ns, resType, oldID, newID); err != nil {
+		returnResourceError(w, err)
+		return
+	}
+	ReturnOK(w, "success")
+}
+
+// handlerResourceCount return the resource count by type of the ns and its child ns.
+func (s *Service) handlerResourceCount(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	ns := r.FormValue("ns")
+	if ns == "" {
+		ReturnBadRequest(w, ErrInvalidParam)
+		return
+	}
+	counts, err := s.tree.CountResources(r.Context(), ns)
+	if err == common.ErrNodeNotFound {
+		ReturnNotFound(w, err.Error())
+		return
+	} else if err != nil {
+		ReturnServerError(w, err)
+		return
+	}
+	ReturnJson(w, 200, counts)
+}
+
+func (s *Service) handlerResourceTypes(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	ns := r.FormValue("ns")
+	if ns == "" {
+		ReturnBadRequest(w, ErrInvalidParam)
+		return
+	}
+	resTypes, err := s.tree.ResourceTypes(ns)
+	if err != nil {
+		ReturnServerError(w, err)
+		return
+	}
+	ReturnJson(w, 200, resTypes)
+}
+
+func (s *Service) handlerResourceByIndex(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	ns, resType, property, value := r.FormValue("ns"), r.FormValue("type"), r.FormValue("property"), r.FormValue("value")
+	if ns == "" || resType == "" || property == "" || value == "" {
+		ReturnBadRequest(w, ErrInvalidParam)
+		return
+	}
+	rs, err := s.tree.GetResourceByIndex(ns, resType, property, value)
+	if err != nil {
+		returnResourceError(w, err)
+		return
+	}
+	ReturnJson(w, 200, rs)
+}
+
+func (s *Service) handlerResourceDefaultsGet(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	resType := r.FormValue("type")
+	if resType == "" {
+		ReturnBadRequest(w, ErrInvalidParam)
+		return
+	}
+	defaults, err := s.tree.GetResourceDefaults(resType)
+	if err != nil {
+		ReturnServerError(w, err)
+		return
+	}
+	ReturnJson(w, 200, defaults)
+}
+
+// handlerResourceDefaultsSet set the default properties of the resource type, only admin could set defaults.
+func (s *Service) handlerResourceDefaultsSet(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	if !isAdmin(r.Header.Get(`UID`)) {
+		ReturnForbidden(w, "Not Authorized. Only admin could set defaults.")
+		return
+	}
+	buf := new(bytes.Buffer)
+	if _, err := buf.ReadFrom(r.Body); err != nil {
+		ReturnBadRequest(w, err)
+		return
+	}
+	defaults := map[string]string{}
+	if err := json.Unmarshal(buf.Bytes(), &defaults); err != nil {
+		ReturnBadRequest(w, err)
+		return
+	}
+	resType := r.FormValue("type")
+	if resType == "" {
+		ReturnBadRequest(w, ErrInvalidParam)
+		return
+	}
+	if err := s.tree.SetResourceDefaults(resType, defaults); err != nil {
+		ReturnServerError(w, err)
+		return
+	}
+	ReturnOK(w, "success")
+}
+
 func (s *Service) handleUpdateResourceList(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
 	var err error
 	buf := new(bytes.Buffer)
@@ -621,6 +832,72 @@ func (s *Service) handleRemoveResourceList(w http.ResponseWriter, r *http.Reques
 	ReturnJson(w, 200, "OK")
 }
 
+func (s *Service) handlerMachineDecommission(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	hostname := r.FormValue("hostname")
+	if hostname == "" {
+		ReturnBadRequest(w, ErrInvalidParam)
+		return
+	}
+	if err := s.tree.DecommissionMachine(hostname, r.Header.Get(`UID`)); err != nil {
+		s.logger.Errorf("decommission machine %s fail: %s", hostname, err.Error())
+		if err == common.ErrMachineNotFound {
+			ReturnNotFound(w, err.Error())
+			return
+		}
+		ReturnServerError(w, err)
+		return
+	}
+	ReturnOK(w, "success")
+}
+
+// handlerMachineNs return the ns list the machine registered under.
+func (s *Service) handlerMachineNs(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	hostname := r.FormValue("hostname")
+	if hostname == "" {
+		ReturnBadRequest(w, ErrInvalidParam)
+		return
+	}
+	nsList, err := s.tree.MachineNamespaces(hostname)
+	if err != nil {
+		ReturnServerError(w, err)
+		return
+	}
+	ReturnJson(w, 200, nsList)
+}
+
+func (s *Service) handlerMachineArchive(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	ns := r.FormValue("ns")
+	if ns == "" {
+		ReturnBadRequest(w, ErrInvalidParam)
+		return
+	}
+	machines, err := s.tree.GetArchivedMachines(ns)
+	if err != nil {
+		ReturnServerError(w, err)
+		return
+	}
+	ReturnJson(w, 200, machines)
+}
+
+func (s *Service) handlerMachineSearch(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	offset, err1 := strconv.Atoi(r.FormValue("offset"))
+	limit, err2 := strconv.Atoi(r.FormValue("limit"))
+	if err1 != nil || err2 != nil {
+		ReturnBadRequest(w, ErrInvalidParam)
+		return
+	}
+	machines, total, err := s.tree.SearchMachinePaged(r.FormValue("query"), offset, limit, r.FormValue("status"))
+	if err == common.ErrInvalidParam {
+		ReturnBadRequest(w, err)
+		return
+	} else if err != nil {
+		s.logger.Errorf("SearchMachinePaged fail: %s", err.Error())
+		ReturnServerError(w, err)
+		return
+	}
+	ReturnJson(w, 200, map[string]interface{}{"machines": machines, "total": total})
+}
+
 func isProductionUsers(u string) bool {
 	for _, user := range config.C.CommonConf.ProductionUsers {
 		if u == user {
@@ -681,6 +958,45 @@ func (s *Service) handleResourcePut(w http.ResponseWriter, r *http.Request, _ ht
 	ReturnOK(w, "success")
 }
 
+// handlerResourceAppend append the resource list to the ns.
+// All the resources are checked and nothing is appended if any has problem when validate is true.
+func (s *Service) handlerResourceAppend(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	buf := new(bytes.Buffer)
+	if _, err := buf.ReadFrom(r.Body); err != nil {
+		ReturnBadRequest(w, err)
+		return
+	}
+	param := bodyParam{}
+	if err := json.Unmarshal(buf.Bytes(), &param); err != nil {
+		ReturnBadRequest(w, err)
+		return
+	}
+	if param.Ns == "" || param.ResType == "" || len(param.Rl) == 0 {
+		ReturnBadRequest(w, ErrInvalidParam)
+		return
+	}
+
+	// reject all the resources with all the problems found if validate is true.
+	if r.FormValue("validate") == "true" {
+		errs, err := s.tree.ValidateResources(param.Ns, param.ResType, param.Rl...)
+		if err != nil {
+			returnResourceError(w, err)
+			return
+		}
+		if len(errs) != 0 {
+			ReturnJson(w, http.StatusBadRequest, errs)
+			return
+		}
+	}
+
+	if err := s.tree.AppendResource(param.Ns, param.ResType, param.Rl...); err != nil {
+		s.logger.Errorf("append %d %s to ns %s fail: %s", len(param.Rl), param.ResType, param.Ns, err.Error())
+		returnResourceError(w, err)
+		return
+	}
+	ReturnOK(w, "success")
+}
+
 func (s *Service) handlerResourceAdd(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
 	buf := new(bytes.Buffer)
 	if _, err := buf.ReadFrom(r.Body); err != nil {
@@ -769,7 +1085,7 @@ func (s *Service) handlerResourceAdd(w http.ResponseWriter, r *http.Request, _ h
 	}
 
 	if err := s.tree.AppendResource(param.Ns, param.ResType, param.R); err != nil {
-		ReturnServerError(w, err)
+		returnResourceError(w, err)
 	} else {
 		if param.ResType == "collect" {
 			gDevName := authorize.GetNsDevGName(param.Ns)
@@ -821,13 +1137,92 @@ func (s *Service) handleResourceDel(w http.ResponseWriter, r *http.Request, _ ht
 	ns := r.FormValue("ns")
 	resType := r.FormValue("type")
 	resIDs := r.FormValue("resourceid")
-	if err := s.tree.RemoveResource(ns, resType, strings.Split(resIDs, ",")...); err != nil {
-		ReturnServerError(w, err)
+	remove := s.tree.RemoveResource
+	// keep the resource as tombstone which could be restored.
+	if r.FormValue("soft") == "true" {
+		remove = s.tree.SoftRemoveResource
+	}
+	if err := remove(ns, resType, strings.Split(resIDs, ",")...); err != nil {
+		returnResourceError(w, err)
+		return
+	}
+	ReturnOK(w, "success")
+}
+
+// handleResourceTouch update the touch time of the resource.
+func (s *Service) handleResourceTouch(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	ns := r.FormValue("ns")
+	resType := r.FormValue("type")
+	resID := r.FormValue("resourceid")
+	if ns == "" || resType == "" || resID == "" {
+		ReturnBadRequest(w, ErrInvalidParam)
+		return
+	}
+	if err := s.tree.TouchResource(ns, resType, resID); err != nil {
+		returnResourceError(w, err)
 		return
 	}
 	ReturnOK(w, "success")
 }
 
+// handleResourceTouchTime return the unix time the resource is touched last time, 0 if it is never touched.
+func (s *Service) handleResourceTouchTime(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	ns := r.FormValue("ns")
+	resType := r.FormValue("type")
+	resID := r.FormValue("resourceid")
+	if ns == "" || resType == "" || resID == "" {
+		ReturnBadRequest(w, ErrInvalidParam)
+		return
+	}
+	touchTime, err := s.tree.TouchTime(ns, resType, resID)
+	if err != nil {
+		returnResourceError(w, err)
+		return
+	}
+	var unix int64
+	if !touchTime.IsZero() {
+		unix = touchTime.Unix()
+	}
+	ReturnJson(w, 200, unix)
+}
+
+// handleResourceRestore restore the soft removed resources.
+func (s *Service) handleResourceRestore(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	ns := r.FormValue("ns")
+	resType := r.FormValue("type")
+	resIDs := r.FormValue("resourceid")
+	if ns == "" || resType == "" || resIDs == "" {
+		ReturnBadRequest(w, ErrInvalidParam)
+		return
+	}
+	if err := s.tree.RestoreResource(ns, resType, strings.Split(resIDs, ",")...); err != nil {
+		returnResourceError(w, err)
+		return
+	}
+	ReturnOK(w, "success")
+}
+
+// returnResourceError return 400 if the resource operation is not allowed on the node,
+// and 409 if the pk of resource already exist.
+func returnResourceError(w http.ResponseWriter, err error) {
+	if _, ok := err.(*node.NodeTypeError); ok {
+		ReturnBadRequest(w, err)
+		return
+	}
+	switch err {
+	case resource.ErrNotFound:
+		ReturnNotFound(w, err.Error())
+	case resource.ErrResourcePkExist:
+		ReturnJson(w, http.StatusConflict, err.Error())
+	case resource.ErrResourceIDExist, model.ErrInvalidUUID, common.ErrInvalidParam:
+		ReturnBadRequest(w, err)
+	case common.ErrQuotaExceeded:
+		ReturnForbidden(w, err.Error())
+	default:
+		ReturnServerError(w, err)
+	}
+}
+
 // handleCollectDel handle the delete collect request.
 // delete the collect resource and clear data in db.
 func (s *Service) handleCollectDel(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
@@ -1109,6 +1504,49 @@ func (s *Service) handlerAgents(w http.ResponseWriter, r *http.Request, _ httpro
 	ReturnJson(w, 200, s.tree.GetReportInfo())
 }
 
+// handlerAgentsHealth return the machine health seen by this registry node,
+// other node may answer different health for the machine not reporting to it.
+func (s *Service) handlerAgentsHealth(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	ns := r.FormValue("ns")
+	if ns == "" {
+		ReturnBadRequest(w, ErrInvalidParam)
+		return
+	}
+	summary, err := s.tree.NodeHealth(ns)
+	if err != nil {
+		ReturnServerError(w, err)
+		return
+	}
+	ReturnJson(w, 200, summary)
+}
+
+func (s *Service) handlerNsHash(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	ns := r.FormValue("ns")
+	if ns == "" {
+		ReturnBadRequest(w, ErrInvalidParam)
+		return
+	}
+	hash, err := s.tree.NamespaceHash(r.Context(), ns, r.FormValue("recursive") == "true")
+	if err == common.ErrNodeNotFound {
+		ReturnNotFound(w, err.Error())
+		return
+	} else if err != nil {
+		ReturnServerError(w, err)
+		return
+	}
+	ReturnJson(w, 200, map[string]string{"ns": ns, "hash": hash})
+}
+
+// handlerMetrics return the node count, depth, resource count and dashboard count of the tree.
+func (s *Service) handlerMetrics(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	metrics, err := s.tree.Metrics()
+	if err != nil {
+		ReturnServerError(w, err)
+		return
+	}
+	ReturnJson(w, 200, metrics)
+}
+
 func (s *Service) handlerAgent(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
 	paraIP := r.FormValue("ip")
 	paraNS := r.FormValue("ns")
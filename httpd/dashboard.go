@@ -8,41 +8,85 @@ import (
 
 	"github.com/julienschmidt/httprouter"
 
+	"github.com/lodastack/registry/common"
 	"github.com/lodastack/registry/model"
+	"github.com/lodastack/registry/tree"
+	"github.com/lodastack/registry/tree/node"
 )
 
 func (s *Service) initDashboardHandler() {
 	s.router.GET("/api/v1/dashboard", s.handlerDashboardGet)
+	s.router.GET("/api/v1/dashboard/page", s.handlerDashboardPage)
 	s.router.POST("/api/v1/dashboard", s.handlerDashboardSet)
 	s.router.PUT("/api/v1/dashboard", s.handlerDashboardPut)
 	s.router.POST("/api/v1/dashboard/add", s.handlerDashboardAdd)
+	s.router.POST("/api/v1/dashboard/validate", s.handlerDashboardValidate)
 	s.router.DELETE("/api/v1/dashboard", s.handlerDashboardDel)
 
+	s.router.GET("/api/v1/dashboard/panel", s.handlerPanelGet)
 	s.router.POST("/api/v1/dashboard/panel", s.handlerPanelPost)
 	s.router.PUT("/api/v1/dashboard/panel", s.handlerPanelPut)
 	s.router.PUT("/api/v1/dashboard/panel/order", s.handlerPanelReorder)
 	s.router.DELETE("/api/v1/dashboard/panel", s.handlerPanelDel)
+	s.router.POST("/api/v1/dashboard/panel/copy", s.handlerPanelTransfer)
+	s.router.PUT("/api/v1/dashboard/panel/move", s.handlerPanelTransfer)
 
 	s.router.POST("/api/v1/dashboard/target", s.handlerTargetPost)
 	s.router.PUT("/api/v1/dashboard/target", s.handlerTargetPut)
 	s.router.DELETE("/api/v1/dashboard/target", s.handlerTargetDelete)
 }
 
+// returnDashboardError return 400 if the dashboard/panel/target index is out of range
+// or the node could not have dashboard, otherwise return 500.
+func returnDashboardError(w http.ResponseWriter, err error) {
+	if _, ok := err.(*tree.IndexError); ok {
+		ReturnBadRequest(w, err)
+		return
+	}
+	if _, ok := err.(*node.NodeTypeError); ok {
+		ReturnBadRequest(w, err)
+		return
+	}
+	ReturnServerError(w, err)
+}
+
 func (s *Service) handlerDashboardGet(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
 	ns := r.FormValue("ns")
 	if ns == "" {
 		ReturnBadRequest(w, ErrInvalidParam)
 		return
 	}
-	dashboards, err := s.tree.GetDashboard(ns)
+	dashboards, version, err := s.tree.GetDashboardWithVersion(ns)
 	if err != nil {
 		s.logger.Errorf("handlerDashboardGet GetDashboard fail: %s", err.Error())
 		ReturnServerError(w, err)
 		return
 	}
+	// the version is passed back when set the dashboards to check whether others changed it.
+	w.Header().Set(`Version`, version)
 	ReturnJson(w, 200, dashboards)
 }
 
+func (s *Service) handlerDashboardPage(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	ns := r.FormValue("ns")
+	offset, err1 := strconv.Atoi(r.FormValue("offset"))
+	limit, err2 := strconv.Atoi(r.FormValue("limit"))
+	if ns == "" || err1 != nil || err2 != nil {
+		ReturnBadRequest(w, ErrInvalidParam)
+		return
+	}
+	dashboards, total, err := s.tree.GetDashboardsPaged(ns, offset, limit)
+	if err == common.ErrInvalidParam {
+		ReturnBadRequest(w, err)
+		return
+	} else if err != nil {
+		s.logger.Errorf("GetDashboardsPaged fail: %s", err.Error())
+		ReturnServerError(w, err)
+		return
+	}
+	ReturnJson(w, 200, map[string]interface{}{"dashboards": dashboards, "total": total})
+}
+
 func (s *Service) handlerDashboardAdd(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
 	buf := new(bytes.Buffer)
 	if _, err := buf.ReadFrom(r.Body); err != nil {
@@ -59,7 +103,7 @@ func (s *Service) handlerDashboardAdd(w http.ResponseWriter, r *http.Request, _
 	ns := r.FormValue("ns")
 	if err := s.tree.AddDashboard(ns, dashboard); err != nil {
 		s.logger.Errorf("handlerDashboardGet SetDashboard fail: %s", err.Error())
-		ReturnServerError(w, err)
+		returnDashboardError(w, err)
 		return
 	}
 	ReturnJson(w, 200, "OK")
@@ -78,15 +122,53 @@ func (s *Service) handlerDashboardSet(w http.ResponseWriter, r *http.Request, _
 		return
 	}
 
+	// reject the dashboards which has problem if validate is true.
+	if r.FormValue("validate") == "true" {
+		errs, err := s.tree.ValidateDashboards(buf.Bytes())
+		if err != nil {
+			ReturnBadRequest(w, err)
+			return
+		}
+		if len(errs) != 0 {
+			ReturnJson(w, http.StatusBadRequest, errs)
+			return
+		}
+	}
+
 	ns := r.FormValue("ns")
-	if err := s.tree.SetDashboard(ns, dashboards); err != nil {
+	var err error
+	// only set the dashboards if not changed since the version read, if version is provided.
+	// The check is exact for the requests to this registry node only, see SetDashboardIfVersion.
+	if version, ok := r.URL.Query()["version"]; ok {
+		err = s.tree.SetDashboardIfVersion(ns, dashboards, version[0])
+	} else {
+		err = s.tree.SetDashboard(ns, dashboards)
+	}
+	if err == tree.ErrVersionMismatch {
+		ReturnJson(w, http.StatusConflict, err.Error())
+		return
+	} else if err != nil {
 		s.logger.Errorf("handlerDashboardGet SetDashboard fail: %s", err.Error())
-		ReturnServerError(w, err)
+		returnDashboardError(w, err)
 		return
 	}
 	ReturnJson(w, 200, "OK")
 }
 
+func (s *Service) handlerDashboardValidate(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	buf := new(bytes.Buffer)
+	if _, err := buf.ReadFrom(r.Body); err != nil {
+		ReturnBadRequest(w, err)
+		return
+	}
+	errs, err := s.tree.ValidateDashboards(buf.Bytes())
+	if err != nil {
+		ReturnBadRequest(w, err)
+		return
+	}
+	ReturnJson(w, 200, errs)
+}
+
 func (s *Service) handlerDashboardPut(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
 	ns, dIndex, title := r.FormValue("ns"), r.FormValue("dindex"), r.FormValue("title")
 	i, err := strconv.Atoi(dIndex)
@@ -97,7 +179,7 @@ func (s *Service) handlerDashboardPut(w http.ResponseWriter, r *http.Request, _
 
 	if err := s.tree.UpdateDashboard(ns, i, title); err != nil {
 		s.logger.Errorf("handlerDashboardPut GetDashboard fail: %s", err.Error())
-		ReturnServerError(w, err)
+		returnDashboardError(w, err)
 		return
 	}
 	ReturnJson(w, 200, "OK")
@@ -118,6 +200,27 @@ func (s *Service) handlerDashboardDel(w http.ResponseWriter, r *http.Request, _
 	ReturnJson(w, 200, "OK")
 }
 
+func (s *Service) handlerPanelGet(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	ns := r.FormValue("ns")
+	dIndex, err1 := strconv.Atoi(r.FormValue("dindex"))
+	offset, err2 := strconv.Atoi(r.FormValue("offset"))
+	limit, err3 := strconv.Atoi(r.FormValue("limit"))
+	if ns == "" || err1 != nil || err2 != nil || err3 != nil {
+		ReturnBadRequest(w, ErrInvalidParam)
+		return
+	}
+	panels, total, err := s.tree.GetDashboardPanels(ns, dIndex, offset, limit)
+	if err == common.ErrInvalidParam {
+		ReturnBadRequest(w, err)
+		return
+	} else if err != nil {
+		s.logger.Errorf("GetDashboardPanels fail: %s", err.Error())
+		ReturnServerError(w, err)
+		return
+	}
+	ReturnJson(w, 200, map[string]interface{}{"panels": panels, "total": total})
+}
+
 func (s *Service) handlerPanelPost(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
 	buf := new(bytes.Buffer)
 	if _, err := buf.ReadFrom(r.Body); err != nil {
@@ -139,7 +242,7 @@ func (s *Service) handlerPanelPost(w http.ResponseWriter, r *http.Request, _ htt
 	}
 	if err := s.tree.AddPanel(ns, i, panel); err != nil {
 		s.logger.Errorf("AddPanel fail: %s", err.Error())
-		ReturnServerError(w, err)
+		returnDashboardError(w, err)
 		return
 	}
 	ReturnJson(w, 200, "OK")
@@ -185,7 +288,7 @@ func (s *Service) handlerPanelReorder(w http.ResponseWriter, r *http.Request, _
 
 	if err := s.tree.ReorderPanel(ns, i, newOrder); err != nil {
 		s.logger.Errorf("AddPanel fail: %s", err.Error())
-		ReturnServerError(w, err)
+		returnDashboardError(w, err)
 		return
 	}
 	ReturnJson(w, 200, "OK")
@@ -199,9 +302,44 @@ func (s *Service) handlerPanelDel(w http.ResponseWriter, r *http.Request, _ http
 		ReturnBadRequest(w, ErrInvalidParam)
 		return
 	}
+	// remove the dashboard if it has no panel left and removeempty is true.
+	if r.FormValue("removeempty") == "true" {
+		removed, err := s.tree.RemovePanelOrDashboard(ns, dI, pI, true)
+		if err != nil {
+			s.logger.Errorf("RemovePanelOrDashboard fail: %s", err.Error())
+			returnDashboardError(w, err)
+			return
+		}
+		ReturnJson(w, 200, map[string]bool{"dashboardremoved": removed})
+		return
+	}
 	if err := s.tree.RemovePanel(ns, dI, pI); err != nil {
 		s.logger.Errorf("AddPanel fail: %s", err.Error())
-		ReturnServerError(w, err)
+		returnDashboardError(w, err)
+		return
+	}
+	ReturnJson(w, 200, "OK")
+}
+
+// handlerPanelTransfer copy the panel to the dashboard of other ns, or move it if the method is PUT.
+func (s *Service) handlerPanelTransfer(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	fromNs, toNs := r.FormValue("fromns"), r.FormValue("tons")
+	fromDI, errFrom := strconv.Atoi(r.FormValue("fromdindex"))
+	pI, errP := strconv.Atoi(r.FormValue("pindex"))
+	toDI, errTo := strconv.Atoi(r.FormValue("todindex"))
+	if fromNs == "" || toNs == "" || errFrom != nil || errP != nil || errTo != nil {
+		ReturnBadRequest(w, ErrInvalidParam)
+		return
+	}
+	var err error
+	if r.Method == http.MethodPut {
+		err = s.tree.MovePanel(fromNs, fromDI, pI, toNs, toDI)
+	} else {
+		err = s.tree.CopyPanel(fromNs, fromDI, pI, toNs, toDI)
+	}
+	if err != nil {
+		s.logger.Errorf("transfer panel fail: %s", err.Error())
+		returnDashboardError(w, err)
 		return
 	}
 	ReturnJson(w, 200, "OK")
@@ -229,7 +367,8 @@ func (s *Service) handlerTargetPost(w http.ResponseWriter, r *http.Request, _ ht
 	}
 
 	if err := s.tree.AppendTarget(ns, dI, pI, target); err != nil {
-		ReturnServerError(w, err)
+		returnDashboardError(w, err)
+		return
 	}
 	ReturnJson(w, 200, "OK")
 }
@@ -257,7 +396,8 @@ func (s *Service) handlerTargetPut(w http.ResponseWriter, r *http.Request, _ htt
 	}
 
 	if err := s.tree.UpdateTarget(ns, dI, pI, tI, target); err != nil {
-		ReturnServerError(w, err)
+		returnDashboardError(w, err)
+		return
 	}
 	ReturnJson(w, 200, "OK")
 }
@@ -272,7 +412,8 @@ func (s *Service) handlerTargetDelete(w http.ResponseWriter, r *http.Request, _
 		return
 	}
 	if err := s.tree.RemoveTarget(ns, dI, pI, tI); err != nil {
-		ReturnServerError(w, err)
+		returnDashboardError(w, err)
+		return
 	}
 	ReturnJson(w, 200, "OK")
 }
@@ -5,10 +5,17 @@ import (
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"strings"
+
+	"github.com/lodastack/registry/model"
+	"github.com/lodastack/registry/tree"
 
 	"github.com/julienschmidt/httprouter"
 )
 
+// logLevels is the level lodastack/log support.
+var logLevels = map[string]bool{"DEBUG": true, "INFO": true, "WARNING": true, "ERROR": true, "FATAL": true}
+
 func (s *Service) initManageHandler() {
 	s.router.GET("/api/v1/stats", s.handlerStats)
 	s.router.GET("/api/v1/peer", s.handlerPeers)
@@ -16,6 +23,63 @@ func (s *Service) initManageHandler() {
 	s.router.DELETE("/api/v1/peer", s.handlerRemove)
 	s.router.GET("/api/v1/db/backup", s.handlerBackup)
 	s.router.GET("/api/v1/db/restore", s.handlerRestore)
+	s.router.GET("/api/v1/db/repair", s.handlerRepairPreview)
+	s.router.PUT("/api/v1/db/repair", s.handlerRepair)
+	s.router.PUT("/api/v1/log/level", s.handlerLogLevel)
+	s.router.GET("/api/v1/db/config", s.handlerConfigExport)
+	s.router.POST("/api/v1/db/config", s.handlerConfigImport)
+}
+
+func (s *Service) handlerConfigExport(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	data, err := s.tree.ExportConfig(r.Context())
+	if err != nil {
+		ReturnServerError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	ReturnByte(w, http.StatusOK, data)
+}
+
+// handlerConfigImport apply the config document to the tree, only admin could import config.
+func (s *Service) handlerConfigImport(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	if !isAdmin(r.Header.Get(`UID`)) {
+		ReturnForbidden(w, "Not Authorized. Only admin could import config.")
+		return
+	}
+	data, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		ReturnBadRequest(w, fmt.Errorf("read body fail"))
+		return
+	}
+	if err := s.tree.ImportConfig(data); err != nil {
+		ReturnBadRequest(w, err)
+		return
+	}
+	ReturnOK(w, "success")
+}
+
+// handlerLogLevel set the log level of a subsystem of the registry process at runtime,
+// e.g. tree, http, dns or main. The level of all the subsystems is set if subsystem is empty.
+// Only admin could set the log level.
+// NOTE: the store, raft and cache log are written by the store package with the logger given at start,
+// their level could not be set here until the store support changing it.
+func (s *Service) handlerLogLevel(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	if !isAdmin(r.Header.Get(`UID`)) {
+		ReturnForbidden(w, "Not Authorized. Only admin could set log level.")
+		return
+	}
+	level := strings.ToUpper(r.FormValue("level"))
+	subsystem := r.FormValue("subsystem")
+	if !logLevels[level] {
+		ReturnBadRequest(w, ErrInvalidParam)
+		return
+	}
+	if err := model.SetLoggerLevel(subsystem, level); err != nil {
+		ReturnBadRequest(w, fmt.Errorf("%s, subsystem should be one of %v", err.Error(), model.LoggerSubsystems()))
+		return
+	}
+	s.logger.Infof("set log level of subsystem %q to %s by %s", subsystem, level, r.Header.Get("UID"))
+	ReturnOK(w, "success")
 }
 
 func (s *Service) handlerStats(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
@@ -109,3 +173,40 @@ func (s *Service) handlerRestore(w http.ResponseWriter, r *http.Request, _ httpr
 		ReturnOK(w, "success")
 	}
 }
+
+func (s *Service) handlerRepairPreview(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	ns, resType := r.FormValue("ns"), r.FormValue("type")
+	if ns == "" || resType == "" {
+		ReturnBadRequest(w, ErrInvalidParam)
+		return
+	}
+	report, err := s.tree.PreviewRepair(ns, resType)
+	if err != nil {
+		ReturnServerError(w, err)
+		return
+	}
+	ReturnJson(w, 200, report)
+}
+
+// handlerRepair quarantine and reset the unparseable value, only admin could repair.
+func (s *Service) handlerRepair(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	if !isAdmin(r.Header.Get(`UID`)) {
+		ReturnForbidden(w, "Not Authorized. Only admin could repair.")
+		return
+	}
+	ns, resType := r.FormValue("ns"), r.FormValue("type")
+	if ns == "" || resType == "" {
+		ReturnBadRequest(w, ErrInvalidParam)
+		return
+	}
+	report, err := s.tree.RepairValueWithReport(ns, resType)
+	if err == tree.ErrBackupKeyExist {
+		ReturnJson(w, http.StatusConflict, err.Error())
+		return
+	} else if err != nil {
+		s.logger.Errorf("repair ns %s type %s fail: %s", ns, resType, err.Error())
+		ReturnServerError(w, err)
+		return
+	}
+	ReturnJson(w, 200, report)
+}
@@ -0,0 +1,82 @@
+package httpd
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lodastack/log"
+	"github.com/lodastack/registry/config"
+	"github.com/lodastack/registry/model"
+)
+
+// logContains return whether any log file under dir contains msg.
+func logContains(dir, msg string) bool {
+	found := false
+	filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return nil
+		}
+		if data, err := ioutil.ReadFile(path); err == nil && strings.Contains(string(data), msg) {
+			found = true
+		}
+		return nil
+	})
+	return found
+}
+
+func TestHandlerLogLevel(t *testing.T) {
+	dir, err := ioutil.TempDir("", "registry-log")
+	if err != nil {
+		t.Fatalf("create log dir fail: %s", err.Error())
+	}
+	defer os.RemoveAll(dir)
+	if model.LogBackend, err = log.NewFileBackend(dir); err != nil {
+		t.Fatalf("create log backend fail: %s", err.Error())
+	}
+	s := &Service{logger: model.NewLogger("INFO", "httptest")}
+	other := model.NewLogger("INFO", "othertest")
+
+	admins := config.C.CommonConf.Admins
+	config.C.CommonConf.Admins = []string{"admin"}
+	defer func() { config.C.CommonConf.Admins = admins }()
+
+	s.logger.Debugf("debug before set level")
+	for _, c := range []struct {
+		uid   string
+		query string
+		code  int
+	}{
+		{"user", "subsystem=httptest&level=debug", http.StatusForbidden},
+		{"admin", "subsystem=httptest&level=debug", http.StatusOK},
+		{"admin", "subsystem=notexist&level=debug", http.StatusBadRequest},
+		{"admin", "subsystem=httptest&level=notexist", http.StatusBadRequest},
+	} {
+		req := httptest.NewRequest("PUT", "http://loda.com/api/v1/log/level?"+c.query, nil)
+		req.Header.Set("UID", c.uid)
+		w := httptest.NewRecorder()
+		s.handlerLogLevel(w, req, nil)
+		if w.Code != c.code {
+			t.Fatalf("set log level by %s not match with expect: %d", c.query, w.Code)
+		}
+	}
+
+	// only the debug log of the subsystem set is written.
+	s.logger.Debugf("debug after set level")
+	other.Debugf("debug of other subsystem")
+	deadline := time.Now().Add(10 * time.Second)
+	for !logContains(dir, "debug after set level") {
+		if time.Now().After(deadline) {
+			t.Fatal("debug log not found after set level to debug")
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	if logContains(dir, "debug before set level") || logContains(dir, "debug of other subsystem") {
+		t.Fatal("debug log is written by the logger of info level")
+	}
+}
@@ -2,28 +2,28 @@ package httpd
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/lodastack/registry/config"
 
 	"github.com/go-ldap/ldap"
 )
 
-// LDAPAuth for auth user
-func LDAPAuth(username string, password string) error {
-	if password == "" || username == "" {
-		return fmt.Errorf("need username or password")
-	}
+// defaultGroupAttr is the attribute of the user entry which list the groups the user is member of.
+const defaultGroupAttr = "memberOf"
 
-	l, err := ldap.Dial("tcp", fmt.Sprintf("%s", config.C.LDAPConf.Server))
-	if err != nil {
-		return err
+func groupAttr() string {
+	if config.C.LDAPConf.GroupAttr != "" {
+		return config.C.LDAPConf.GroupAttr
 	}
-	defer l.Close()
+	return defaultGroupAttr
+}
 
+// searchUser bind with the read only user and return the entry of the username.
+func searchUser(l *ldap.Conn, username string) (*ldap.Entry, error) {
 	// First bind with a read only user
-	err = l.Bind(config.C.LDAPConf.Binddn, config.C.LDAPConf.Password)
-	if err != nil {
-		return err
+	if err := l.Bind(config.C.LDAPConf.Binddn, config.C.LDAPConf.Password); err != nil {
+		return nil, err
 	}
 
 	// Search for the given username
@@ -31,60 +31,93 @@ func LDAPAuth(username string, password string) error {
 		config.C.LDAPConf.Base,
 		ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 0, 0, false,
 		fmt.Sprintf("((%s=%s))", config.C.LDAPConf.UID, username),
-		[]string{""},
+		[]string{groupAttr()},
 		nil,
 	)
 
 	sr, err := l.Search(searchRequest)
 	if err != nil {
-		return err
+		return nil, err
 	}
-
 	if len(sr.Entries) != 1 {
-		return fmt.Errorf("User does not exist or too many entries returned: %d", len(sr.Entries))
+		return nil, fmt.Errorf("User does not exist or too many entries returned: %d", len(sr.Entries))
 	}
+	return sr.Entries[0], nil
+}
 
-	userdn := sr.Entries[0].DN
+// groupName return the name of group, e.g. return "ops" for "cn=ops,ou=Group,dc=example".
+func groupName(dn string) string {
+	rdn := strings.SplitN(dn, ",", 2)[0]
+	if i := strings.Index(rdn, "="); i >= 0 {
+		return strings.TrimSpace(rdn[i+1:])
+	}
+	return strings.TrimSpace(rdn)
+}
 
-	// Bind as the user to verify their password
-	err = l.Bind(userdn, password)
-	if err != nil {
-		return err
+func userGroups(entry *ldap.Entry) []string {
+	groups := []string{}
+	for _, dn := range entry.GetAttributeValues(groupAttr()) {
+		if name := groupName(dn); name != "" {
+			groups = append(groups, name)
+		}
 	}
-	return nil
+	return groups
 }
 
-func LDAPUserExist(username string) bool {
-	if username == "" {
-		return false
+// LDAPAuth for auth user, return the groups the user is member of.
+func LDAPAuth(username string, password string) ([]string, error) {
+	if password == "" || username == "" {
+		return nil, fmt.Errorf("need username or password")
 	}
 
 	l, err := ldap.Dial("tcp", fmt.Sprintf("%s", config.C.LDAPConf.Server))
 	if err != nil {
-		return false
+		return nil, err
 	}
 	defer l.Close()
 
-	// First bind with a read only user
-	if err = l.Bind(config.C.LDAPConf.Binddn, config.C.LDAPConf.Password); err != nil {
-		return false
+	entry, err := searchUser(l, username)
+	if err != nil {
+		return nil, err
 	}
 
-	// Search for the given username
-	searchRequest := ldap.NewSearchRequest(
-		config.C.LDAPConf.Base,
-		ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 0, 0, false,
-		fmt.Sprintf("((%s=%s))", config.C.LDAPConf.UID, username),
-		[]string{""},
-		nil,
-	)
+	// Bind as the user to verify their password
+	if err = l.Bind(entry.DN, password); err != nil {
+		return nil, err
+	}
+	return userGroups(entry), nil
+}
 
-	sr, err := l.Search(searchRequest)
+// LDAPGroups return the groups the user is member of.
+func LDAPGroups(username string) ([]string, error) {
+	if username == "" {
+		return nil, fmt.Errorf("need username")
+	}
+
+	l, err := ldap.Dial("tcp", fmt.Sprintf("%s", config.C.LDAPConf.Server))
 	if err != nil {
+		return nil, err
+	}
+	defer l.Close()
+
+	entry, err := searchUser(l, username)
+	if err != nil {
+		return nil, err
+	}
+	return userGroups(entry), nil
+}
+
+func LDAPUserExist(username string) bool {
+	if username == "" {
 		return false
 	}
-	if len(sr.Entries) != 1 {
+
+	l, err := ldap.Dial("tcp", fmt.Sprintf("%s", config.C.LDAPConf.Server))
+	if err != nil {
 		return false
 	}
-	return true
+	defer l.Close()
+
+	_, err = searchUser(l, username)
+	return err == nil
 }
@@ -0,0 +1,70 @@
+package httpd
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/lodastack/registry/common"
+	"github.com/lodastack/registry/config"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+func isAdmin(u string) bool {
+	for _, admin := range config.C.CommonConf.Admins {
+		if u == admin {
+			return true
+		}
+	}
+	return false
+}
+
+// handlerQuotaGet return the quota and usage of the ns, or all ns which has quota if ns is empty.
+func (s *Service) handlerQuotaGet(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	ns := r.FormValue("ns")
+	if ns == "" {
+		usages, err := s.tree.ListQuotaUsage(r.Context())
+		if err != nil {
+			ReturnServerError(w, err)
+			return
+		}
+		ReturnJson(w, 200, usages)
+		return
+	}
+	usage, err := s.tree.GetQuotaUsage(r.Context(), ns)
+	if err == common.ErrNodeNotFound {
+		ReturnNotFound(w, err.Error())
+		return
+	} else if err != nil {
+		ReturnServerError(w, err)
+		return
+	}
+	ReturnJson(w, 200, usage)
+}
+
+// handlerQuotaSet set the quota of the ns, only admin could set quota.
+func (s *Service) handlerQuotaSet(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	if !isAdmin(r.Header.Get(`UID`)) {
+		ReturnForbidden(w, "Not Authorized. Only admin could set quota.")
+		return
+	}
+	ns := r.FormValue("ns")
+	quota, err := strconv.Atoi(r.FormValue("quota"))
+	if ns == "" || err != nil {
+		ReturnBadRequest(w, ErrInvalidParam)
+		return
+	}
+	if err := s.tree.SetQuota(ns, quota); err != nil {
+		s.logger.Errorf("set quota of ns %s fail: %s", ns, err.Error())
+		switch err {
+		case common.ErrInvalidParam:
+			ReturnBadRequest(w, err)
+		case common.ErrNodeNotFound:
+			ReturnNotFound(w, err.Error())
+		default:
+			ReturnServerError(w, err)
+		}
+		return
+	}
+	ReturnOK(w, "success")
+}
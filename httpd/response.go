@@ -4,10 +4,16 @@ import (
 	"encoding/json"
 	"errors"
 	"net/http"
+	"strconv"
+
+	"github.com/lodastack/registry/model"
 
 	m "github.com/lodastack/models"
 )
 
+// streamThreshold is the resource number above which the resource list already read is written as stream.
+const streamThreshold = 1000
+
 var errMarshalOutput = errors.New("Marshal JSON output fail.")
 
 type Response m.Response
@@ -67,3 +73,73 @@ func ReturnByte(w http.ResponseWriter, httpStatus int, msg []byte) {
 	w.WriteHeader(httpStatus)
 	w.Write([]byte(msg))
 }
+
+// ReturnResourceStream write the resources visited by walk to response one by one,
+// so the whole response body is not built in memory. The body is the same as ReturnJson.
+// The status is written with the first resource, if walk fail before it, server error is returned instead.
+// If walk fail after the status is written, the body is ended with the error field,
+// e.g. {"httpstatus":200,"data":[...],"msg":"","error":"..."}, the client should check it.
+func ReturnResourceStream(w http.ResponseWriter, httpStatus int, walk func(fn func(model.Resource) error) error) error {
+	if httpStatus == 0 {
+		httpStatus = http.StatusOK
+	}
+	started := false
+	start := func() error {
+		started = true
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(httpStatus)
+		_, err := w.Write([]byte(`{"httpstatus":` + strconv.Itoa(httpStatus) + `,"data":[`))
+		return err
+	}
+	flusher, _ := w.(http.Flusher)
+	i := 0
+	err := walk(func(r model.Resource) error {
+		body, err := json.Marshal(r)
+		if err != nil {
+			return err
+		}
+		if !started {
+			if err := start(); err != nil {
+				return err
+			}
+		} else if _, err := w.Write([]byte(",")); err != nil {
+			return err
+		}
+		if _, err := w.Write(body); err != nil {
+			return err
+		}
+		i++
+		if flusher != nil && i%streamThreshold == 0 {
+			flusher.Flush()
+		}
+		return nil
+	})
+	if err != nil {
+		if !started {
+			ReturnServerError(w, err)
+			return err
+		}
+		msg, _ := json.Marshal(err.Error())
+		w.Write([]byte(`],"msg":"","error":` + string(msg) + `}`))
+		return err
+	}
+	if !started {
+		if err := start(); err != nil {
+			return err
+		}
+	}
+	_, err = w.Write([]byte(`],"msg":""}`))
+	return err
+}
+
+// walkList return the walk func of the resource list already in memory for ReturnResourceStream.
+func walkList(rl model.ResourceList) func(fn func(model.Resource) error) error {
+	return func(fn func(model.Resource) error) error {
+		for _, r := range rl {
+			if err := fn(r); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
@@ -2,10 +2,13 @@ package httpd
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+
+	"github.com/lodastack/registry/model"
 )
 
 func TestWriteJson(t *testing.T) {
@@ -70,3 +73,60 @@ func TestWriteStatus(t *testing.T) {
 		t.Fatalf("ReturnServerError return not match with expect,code: %d, resp: %+v\n", w.Code, resp)
 	}
 }
+
+func TestReturnResourceStream(t *testing.T) {
+	rl := model.ResourceList{{"hostname": "host1"}, {"hostname": "host2", "ip": "127.0.0.1"}}
+
+	w := httptest.NewRecorder()
+	if err := ReturnResourceStream(w, 0, walkList(rl)); err != nil {
+		t.Fatalf("write resource stream fail: %s", err.Error())
+	}
+	var stream, expect struct {
+		Code int                `json:"httpstatus"`
+		Data model.ResourceList `json:"data"`
+		Msg  string             `json:"msg"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &stream); err != nil {
+		t.Fatalf("unmarshal stream response fail: %s, body: %s", err.Error(), w.Body.String())
+	}
+	w2 := httptest.NewRecorder()
+	ReturnJson(w2, 200, rl)
+	if err := json.Unmarshal(w2.Body.Bytes(), &expect); err != nil {
+		t.Fatalf("unmarshal response fail: %s", err.Error())
+	}
+	if w.Code != 200 || stream.Code != expect.Code || len(stream.Data) != len(expect.Data) ||
+		stream.Data[1]["ip"] != "127.0.0.1" {
+		t.Fatalf("stream response not match with expect: %+v, %+v", stream, expect)
+	}
+
+	w = httptest.NewRecorder()
+	ReturnResourceStream(w, 200, walkList(nil))
+	if err := json.Unmarshal(w.Body.Bytes(), &stream); err != nil || len(stream.Data) != 0 {
+		t.Fatalf("stream empty resource list not match with expect: %s, %v", w.Body.String(), err)
+	}
+
+	// the error before the first resource is returned as server error,
+	// the error after it end the body with the error field.
+	errWalk := errors.New("walk fail")
+	w = httptest.NewRecorder()
+	if err := ReturnResourceStream(w, 200, func(fn func(model.Resource) error) error { return errWalk }); err != errWalk {
+		t.Fatalf("stream fail before write not match with expect: %v", err)
+	}
+	if w.Code != http.StatusInternalServerError {
+		t.Fatalf("status of stream fail before write not match with expect: %d", w.Code)
+	}
+	w = httptest.NewRecorder()
+	err := ReturnResourceStream(w, 200, func(fn func(model.Resource) error) error {
+		if err := fn(rl[0]); err != nil {
+			return err
+		}
+		return errWalk
+	})
+	var broken struct {
+		Data  model.ResourceList `json:"data"`
+		Error string             `json:"error"`
+	}
+	if err != errWalk || json.Unmarshal(w.Body.Bytes(), &broken) != nil || len(broken.Data) != 1 || broken.Error != errWalk.Error() {
+		t.Fatalf("stream fail after write not match with expect: %v, body: %s", err, w.Body.String())
+	}
+}
@@ -0,0 +1,106 @@
+package httpd
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/lodastack/registry/config"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+const defaultGroupTTL = 5 * time.Minute
+
+var (
+	// ErrSessionNotFound is returned if the token has no session.
+	ErrSessionNotFound = errors.New("session not found")
+)
+
+// SessionUser is the user of the session and the ldap groups the user is member of.
+type SessionUser struct {
+	User   string   `json:"user"`
+	Groups []string `json:"groups"`
+}
+
+type groupEntry struct {
+	groups []string
+	expire time.Time
+}
+
+// groupCache cache the ldap groups of user for ttl.
+// The session only save the username, so the groups is cached by username.
+type groupCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	entries map[string]groupEntry
+}
+
+func newGroupCache(ttl time.Duration) *groupCache {
+	if ttl <= 0 {
+		ttl = defaultGroupTTL
+	}
+	return &groupCache{ttl: ttl, entries: make(map[string]groupEntry)}
+}
+
+func (c *groupCache) get(user string) ([]string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[user]
+	if !ok {
+		return nil, false
+	}
+	if time.Now().After(e.expire) {
+		delete(c.entries, user)
+		return nil, false
+	}
+	return e.groups, true
+}
+
+func (c *groupCache) set(user string, groups []string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[user] = groupEntry{groups: groups, expire: time.Now().Add(c.ttl)}
+}
+
+// SessionUser return the user of the token and its ldap groups.
+// The groups is looked up from ldap if not cached.
+func (s *Service) SessionUser(token string) (*SessionUser, error) {
+	v := s.cluster.GetSession(token)
+	if v == nil {
+		return nil, ErrSessionNotFound
+	}
+	user, ok := v.(string)
+	if !ok {
+		return nil, ErrSessionNotFound
+	}
+	su := &SessionUser{User: user, Groups: []string{}}
+	if !config.C.LDAPConf.Enable {
+		return su, nil
+	}
+	if groups, ok := s.groups.get(user); ok {
+		su.Groups = groups
+		return su, nil
+	}
+	groups, err := LDAPGroups(user)
+	if err != nil {
+		s.logger.Errorf("get ldap groups of user %s fail: %s", user, err.Error())
+		return nil, err
+	}
+	s.groups.set(user, groups)
+	su.Groups = groups
+	return su, nil
+}
+
+func (s *Service) handlerSessionGet(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	su, err := s.SessionUser(r.Header.Get("AuthToken"))
+	if err == ErrSessionNotFound {
+		ReturnUnauthorized(w, "Not Authorized. Please login.")
+		return
+	} else if err != nil {
+		ReturnServerError(w, err)
+		return
+	}
+	ReturnJson(w, 200, su)
+}
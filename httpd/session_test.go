@@ -0,0 +1,38 @@
+package httpd
+
+import (
+	"testing"
+	"time"
+)
+
+func TestGroupName(t *testing.T) {
+	for dn, expect := range map[string]string{
+		"cn=ops,ou=Group,dc=example": "ops",
+		"CN=dev team,OU=Group":       "dev team",
+		"ops":                        "ops",
+		"":                           "",
+	} {
+		if name := groupName(dn); name != expect {
+			t.Fatalf("group name of %s not match with expect: %s", dn, name)
+		}
+	}
+}
+
+func TestGroupCache(t *testing.T) {
+	c := newGroupCache(50 * time.Millisecond)
+	if _, ok := c.get("user"); ok {
+		t.Fatalf("get not cached user success, not match with expect")
+	}
+	c.set("user", []string{"ops"})
+	if groups, ok := c.get("user"); !ok || len(groups) != 1 || groups[0] != "ops" {
+		t.Fatalf("get cached groups not match with expect: %v, %v", groups, ok)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if _, ok := c.get("user"); ok {
+		t.Fatalf("get expired groups success, not match with expect")
+	}
+
+	if c := newGroupCache(0); c.ttl != defaultGroupTTL {
+		t.Fatalf("default ttl not match with expect: %v", c.ttl)
+	}
+}
@@ -26,6 +26,7 @@ func (s *Service) initPermissionHandler() {
 	s.router.POST("/api/v1/user/signin", s.HandlerSignin)
 	s.router.GET("/api/v1/user/wework/signin", s.HandlerWeworkSignin)
 	s.router.GET("/api/v1/user/signout", s.HandlerSignout)
+	s.router.GET("/api/v1/user/session", s.handlerSessionGet)
 
 	s.router.GET("/api/v1/perm/group", s.HandlerGroupGet)
 	s.router.GET("/api/v1/perm/group/list", s.HandlerGroupList)
@@ -58,10 +59,12 @@ func (s *Service) HandlerSignin(w http.ResponseWriter, r *http.Request, _ httpro
 	}
 
 	if config.C.LDAPConf.Enable {
-		if err := LDAPAuth(user, pass); err != nil {
+		groups, err := LDAPAuth(user, pass)
+		if err != nil {
 			ReturnServerError(w, err)
 			return
 		}
+		s.groups.set(user, groups)
 	}
 
 	ok, err := s.perm.CheckUserExist(user)
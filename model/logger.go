@@ -0,0 +1,94 @@
+package model
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"sync/atomic"
+
+	"github.com/lodastack/log"
+)
+
+// ErrUnknownSubsystem is returned if the logger of subsystem is not created by NewLogger.
+var ErrUnknownSubsystem = errors.New("unknown log subsystem")
+
+var (
+	loggersMu sync.Mutex
+	// loggers is the subsystem - logger map created by NewLogger.
+	loggers = map[string]*Logger{}
+)
+
+// Logger is the logger of a subsystem, its level could be changed at runtime by SetLoggerLevel.
+// The *log.Logger is hold by atomic value and replaced as a whole,
+// so the level is changed without race with the goroutines logging by it.
+type Logger struct {
+	v atomic.Value
+}
+
+func (l *Logger) logger() *log.Logger {
+	return l.v.Load().(*log.Logger)
+}
+
+// Logger return the *log.Logger used now, for the library which take *log.Logger, e.g. the store.
+// NOTE: the library keep the returned one, so its level is not changed by SetLoggerLevel.
+func (l *Logger) Logger() *log.Logger {
+	return l.logger()
+}
+
+func (l *Logger) Debugf(format string, v ...interface{})   { l.logger().Debugf(format, v...) }
+func (l *Logger) Info(v ...interface{})                    { l.logger().Info(v...) }
+func (l *Logger) Infof(format string, v ...interface{})    { l.logger().Infof(format, v...) }
+func (l *Logger) Warningf(format string, v ...interface{}) { l.logger().Warningf(format, v...) }
+func (l *Logger) Error(v ...interface{})                   { l.logger().Error(v...) }
+func (l *Logger) Errorf(format string, v ...interface{})   { l.logger().Errorf(format, v...) }
+func (l *Logger) Fatalf(format string, v ...interface{})   { l.logger().Fatalf(format, v...) }
+func (l *Logger) Printf(format string, v ...interface{})   { l.logger().Printf(format, v...) }
+func (l *Logger) Println(v ...interface{})                 { l.logger().Println(v...) }
+
+// NewLogger create the logger of the subsystem with the level and LogBackend,
+// the level could be changed at runtime by SetLoggerLevel.
+// Return the logger already created if the subsystem is created before.
+func NewLogger(level, subsystem string) *Logger {
+	loggersMu.Lock()
+	defer loggersMu.Unlock()
+	if logger, ok := loggers[subsystem]; ok {
+		return logger
+	}
+	logger := &Logger{}
+	logger.v.Store(log.New(level, subsystem, LogBackend))
+	loggers[subsystem] = logger
+	return logger
+}
+
+// SetLoggerLevel set the level of the logger of subsystem, or the level of all loggers and
+// the global log if subsystem is empty.
+// A new *log.Logger is stored to the Logger, so all the holders of the Logger get the new level.
+func SetLoggerLevel(subsystem, level string) error {
+	loggersMu.Lock()
+	defer loggersMu.Unlock()
+	if subsystem == "" {
+		log.SetLogging(level, LogBackend)
+		for name, logger := range loggers {
+			logger.v.Store(log.New(level, name, LogBackend))
+		}
+		return nil
+	}
+	logger, ok := loggers[subsystem]
+	if !ok {
+		return ErrUnknownSubsystem
+	}
+	logger.v.Store(log.New(level, subsystem, LogBackend))
+	return nil
+}
+
+// LoggerSubsystems return the subsystems which logger is created by NewLogger, ordered by name.
+func LoggerSubsystems() []string {
+	loggersMu.Lock()
+	defer loggersMu.Unlock()
+	subsystems := make([]string, 0, len(loggers))
+	for name := range loggers {
+		subsystems = append(subsystems, name)
+	}
+	sort.Strings(subsystems)
+	return subsystems
+}
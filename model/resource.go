@@ -125,6 +125,29 @@ func (rl *ResourceList) Unmarshal(raw []byte) error {
 	return err
 }
 
+// CountResources return the count of resources in the byte without unmarshal them.
+func CountResources(rsByte []byte) (int, error) {
+	count := 0
+	_, err := (&ResourceList{}).WalkRsByte(rsByte, func(rByte []byte, last bool, rlWalk *ResourceList, output []byte) ([]byte, error) {
+		count++
+		return nil, nil
+	})
+	return count, err
+}
+
+// WalkResources unmarshal the resources in the byte one by one and call fn with each,
+// the resource list is not built. If fn return error, processing stops.
+func WalkResources(rsByte []byte, fn func(Resource) error) error {
+	_, err := (&ResourceList{}).WalkRsByte(rsByte, func(rByte []byte, last bool, rlWalk *ResourceList, output []byte) ([]byte, error) {
+		r := Resource{}
+		if err := r.Unmarshal(rByte); err != nil {
+			return nil, errors.New("unmarshal resources fail: " + err.Error())
+		}
+		return nil, fn(r)
+	})
+	return err
+}
+
 // Update resource with resourceID by updateMap.
 // NOTE: will not change resource ID.
 func UpdateResByID(rsByte []byte, ID string, updateMap map[string]string) ([]byte, error) {
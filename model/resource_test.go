@@ -1,6 +1,7 @@
 package model
 
 import (
+	"errors"
 	"fmt"
 	"testing"
 )
@@ -158,6 +159,36 @@ func TestRsMarshal(t *testing.T) {
 	}
 }
 
+func TestCountResources(t *testing.T) {
+	if count, err := CountResources(boltByte); err != nil || count != 2 {
+		t.Fatalf("CountResources not match with expect: %d, error: %v", count, err)
+	}
+	if count, err := CountResources(nil); err != nil || count != 0 {
+		t.Fatalf("CountResources of empty byte not match with expect: %d, error: %v", count, err)
+	}
+}
+
+func TestWalkResources(t *testing.T) {
+	expect, walked := ResourceList{}, []Resource{}
+	if err := expect.Unmarshal(boltByte); err != nil {
+		t.Fatalf("unmarshal fail: %s", err.Error())
+	}
+	if err := WalkResources(boltByte, func(r Resource) error {
+		walked = append(walked, r)
+		return nil
+	}); err != nil || len(walked) != len(expect) || walked[1][IdKey] != expect[1][IdKey] {
+		t.Fatalf("WalkResources not match with expect: %v, error: %v", walked, err)
+	}
+	errStop := errors.New("stop")
+	count := 0
+	if err := WalkResources(boltByte, func(r Resource) error {
+		count++
+		return errStop
+	}); err == nil || count != 1 {
+		t.Fatalf("WalkResources not stop at error: %d, error: %v", count, err)
+	}
+}
+
 func TestRUnmarshal(t *testing.T) {
 	r := Resource{}
 	if err := r.Unmarshal(rByte); err != nil {
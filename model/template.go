@@ -17,6 +17,10 @@ var (
 		Deploy:  "name",
 	}
 
+	// IndexProperty is the resource type - property list which has secondary index,
+	// register by RegisterIndex.
+	IndexProperty = map[string][]string{}
+
 	TemplatePrefix     string = "_template_"
 	TemplateCollectNum int    = len(collectTemplate)
 )
@@ -258,6 +262,18 @@ var (
 	RootTemplate map[string]ResourceList
 )
 
+// RegisterIndex add secondary index of the property to the resource type.
+// Register the index before the tree is used, the resource written before is indexed at next write.
+// The index of the property not registered any more is not used, and removed at next write.
+func RegisterIndex(resType, property string) {
+	for _, p := range IndexProperty[resType] {
+		if p == property {
+			return
+		}
+	}
+	IndexProperty[resType] = append(IndexProperty[resType], property)
+}
+
 func init() {
 	RootTemplate = make(map[string]ResourceList)
 	for _, resType := range Templates {
@@ -0,0 +1,121 @@
+package tree
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/lodastack/registry/common"
+	"github.com/lodastack/registry/model"
+	"github.com/lodastack/registry/tree/cluster"
+)
+
+// archiveBucket save the decommissioned machine, the key is nodeID/resID of the node the machine removed from,
+// so the archive is kept when the node is renamed or moved, and every archived machine is written
+// and purged by its own key without rewriting the others.
+const archiveBucket = "archive"
+
+const (
+	// DecommissionTimeProp is the unix time the machine is decommissioned.
+	DecommissionTimeProp = "decommissiontime"
+	// DecommissionActorProp is the user decommissioned the machine.
+	DecommissionActorProp = "decommissionactor"
+)
+
+func (t *Tree) initArchiveBucket() error {
+	if err := t.cluster.CreateBucketIfNotExist([]byte(archiveBucket)); err != nil {
+		t.logger.Errorf("tree init %s CreateBucketIfNotExist fail: %s", archiveBucket, err.Error())
+		return err
+	}
+
+	return nil
+}
+
+// archivePrefix return the key prefix of the machines archived from the node.
+func archivePrefix(nodeID string) string {
+	return nodeID + "/"
+}
+
+func archiveKey(nodeID, resID string) string {
+	return archivePrefix(nodeID) + resID
+}
+
+// DecommissionMachine remove the machine from all ns and archive it with the decommission time and actor.
+// The machine is removed and archived in one batch.
+func (t *Tree) DecommissionMachine(hostname, actor string) error {
+	machineRecord, err := t.machine.SearchMachine(hostname)
+	if err != nil {
+		t.logger.Errorf("DecommissionMachine search machine fail: %s", err.Error())
+		return err
+	}
+	if len(machineRecord) == 0 {
+		return common.ErrMachineNotFound
+	}
+	now := strconv.FormatInt(time.Now().Unix(), 10)
+	t.retainMu.Lock()
+	defer t.retainMu.Unlock()
+	return t.tx(func(tx *treeTx) error {
+		for ns, resourceID := range machineRecord {
+			nodeID, err := t.getNodeIDByNS(ns)
+			if err != nil {
+				return err
+			}
+			machines, err := tx.GetResource(ns, model.Machine, resourceID[0])
+			if err != nil || len(machines) == 0 {
+				t.logger.Errorf("get machine %s of ns %s fail: %v", hostname, ns, err)
+				return common.ErrMachineNotFound
+			}
+			if err := tx.RemoveResource(ns, model.Machine, resourceID[0]); err != nil {
+				return err
+			}
+
+			machines[0].SetProperty(DecommissionTimeProp, now)
+			machines[0].SetProperty(DecommissionActorProp, actor)
+			resByte, err := (&model.ResourceList{machines[0]}).Marshal()
+			if err != nil {
+				return err
+			}
+			if err := tx.buffer.Update([]byte(archiveBucket), []byte(archiveKey(nodeID, resourceID[0])), resByte); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// readResourceList return the resource list saved in the bucket by key.
+func readResourceList(c cluster.Inf, bucket, key string) (model.ResourceList, error) {
+	rl := model.ResourceList{}
+	resByte, err := c.View([]byte(bucket), []byte(key))
+	if err != nil {
+		return nil, err
+	}
+	if err := rl.Unmarshal(resByte); err != nil && err != common.ErrEmptyResource {
+		return nil, err
+	}
+	return rl, nil
+}
+
+// GetArchivedMachines return the machines decommissioned from the ns and its child ns.
+func (t *Tree) GetArchivedMachines(ns string) (model.ResourceList, error) {
+	leafIDs, err := t.node.LeafChildIDs(ns)
+	if err != nil && err != common.ErrNoLeafChild {
+		t.logger.Errorf("get leaf of ns %s fail: %s", ns, err.Error())
+		return nil, err
+	}
+	result := model.ResourceList{}
+	for _, leafID := range leafIDs {
+		archived, err := readResourcesByPrefix(t.cluster, archiveBucket, archivePrefix(leafID))
+		if err != nil {
+			t.logger.Errorf("read archive of node %s fail: %s", leafID, err.Error())
+			return nil, err
+		}
+		result.AppendResources(archived)
+	}
+	return result, nil
+}
+
+// PurgeArchivedMachines remove the archived machine decommissioned before the time,
+// return the number of machine purged.
+func (t *Tree) PurgeArchivedMachines(before time.Time) (int, error) {
+	return t.purgeExpired(archiveBucket, DecommissionTimeProp, before)
+}
@@ -0,0 +1,100 @@
+package cluster
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/lodastack/store/model"
+)
+
+var (
+	// ErrBucketInBuffer is returned if create or remove bucket by Buffer.
+	ErrBucketInBuffer = errors.New("not support to create or remove bucket in buffer")
+)
+
+// Buffer hold the update in memory instead of writing them to the cluster,
+// read from Buffer return the updated value first.
+// The updates could be written to the cluster in one batch by Rows.
+type Buffer struct {
+	c Inf
+
+	// bucket - key - value.
+	pending map[string]map[string][]byte
+	rows    []model.Row
+}
+
+// NewBuffer return the Buffer which read from c.
+func NewBuffer(c Inf) *Buffer {
+	return &Buffer{c: c, pending: map[string]map[string][]byte{}}
+}
+
+// CreateBucket is not supported by Buffer.
+func (b *Buffer) CreateBucket(name []byte) error {
+	return ErrBucketInBuffer
+}
+
+// CreateBucketIfNotExist is not supported by Buffer.
+func (b *Buffer) CreateBucketIfNotExist(name []byte) error {
+	return ErrBucketInBuffer
+}
+
+// RemoveBucket is not supported by Buffer.
+func (b *Buffer) RemoveBucket(name []byte) error {
+	return ErrBucketInBuffer
+}
+
+// View return the updated value if the key is updated in the buffer, otherwise read from cluster.
+func (b *Buffer) View(bucket, key []byte) ([]byte, error) {
+	if v, ok := b.pending[string(bucket)][string(key)]; ok {
+		return v, nil
+	}
+	return b.c.View(bucket, key)
+}
+
+// Update save the value in the buffer.
+func (b *Buffer) Update(bucket []byte, key []byte, value []byte) error {
+	if _, ok := b.pending[string(bucket)]; !ok {
+		b.pending[string(bucket)] = map[string][]byte{}
+	}
+	if _, ok := b.pending[string(bucket)][string(key)]; !ok {
+		b.rows = append(b.rows, model.Row{Bucket: []byte(string(bucket)), Key: []byte(string(key))})
+	}
+	v := make([]byte, len(value))
+	copy(v, value)
+	b.pending[string(bucket)][string(key)] = v
+	return nil
+}
+
+// Batch save the values in the buffer.
+func (b *Buffer) Batch(rows []model.Row) error {
+	for _, row := range rows {
+		b.Update(row.Bucket, row.Key, row.Value)
+	}
+	return nil
+}
+
+// ViewPrefix return the value of the keys has the keyPrefix, include the key updated in the buffer.
+func (b *Buffer) ViewPrefix(bucket, keyPrefix []byte) (map[string][]byte, error) {
+	result, err := b.c.ViewPrefix(bucket, keyPrefix)
+	if err != nil {
+		return nil, err
+	}
+	if result == nil {
+		result = map[string][]byte{}
+	}
+	for k, v := range b.pending[string(bucket)] {
+		if strings.HasPrefix(k, string(keyPrefix)) {
+			result[k] = v
+		}
+	}
+	return result, nil
+}
+
+// Rows return the updates in the buffer, the key updated multiple times has the last value.
+func (b *Buffer) Rows() []model.Row {
+	rows := make([]model.Row, len(b.rows))
+	for i, row := range b.rows {
+		rows[i] = model.Row{Bucket: row.Bucket, Key: row.Key, Value: b.pending[string(row.Bucket)][string(row.Key)]}
+	}
+	return rows
+}
@@ -0,0 +1,103 @@
+package codec
+
+// Codec encode the value saved in the tree.
+// JSON value is saved as it is, so that the value saved before is still readable.
+// Other format value is saved with a leading marker byte which JSON text never start with,
+// so the value could be decoded whatever the format it was saved in,
+// and migrate to the new format lazily when it is written next time.
+// Only JSON is supported now.
+
+import (
+	"encoding/json"
+	"errors"
+)
+
+const (
+	// JSONName is the name of json codec.
+	JSONName = "json"
+
+	noMarker byte = 0
+)
+
+var (
+	// ErrUnknownCodec is returned if the codec name is not supported.
+	ErrUnknownCodec = errors.New("unknown codec")
+	// ErrUnknownMarker is returned if the value has a unknown format marker.
+	ErrUnknownMarker = errors.New("unknown value format marker")
+
+	// JSON is the default codec.
+	JSON Codec = jsonCodec{}
+)
+
+// Codec is the interface to marshal/unmarshal the value.
+type Codec interface {
+	// Name return the codec name.
+	Name() string
+
+	// Marker is the leading byte of the encoded value, 0 means no marker.
+	Marker() byte
+
+	// Marshal return the encoding of v.
+	Marshal(v interface{}) ([]byte, error)
+
+	// Unmarshal parse the data and stores the result in the value pointed to by v.
+	Unmarshal(data []byte, v interface{}) error
+}
+
+// ByName return the codec by name, return JSON if name is empty.
+func ByName(name string) (Codec, error) {
+	switch name {
+	case "", JSONName:
+		return JSON, nil
+	}
+	return nil, ErrUnknownCodec
+}
+
+// Encode marshal v by the codec and add the format marker.
+func Encode(c Codec, v interface{}) ([]byte, error) {
+	data, err := c.Marshal(v)
+	if err != nil || c.Marker() == noMarker {
+		return data, err
+	}
+	return append([]byte{c.Marker()}, data...), nil
+}
+
+// Decode unmarshal the data by the codec its marker point to.
+func Decode(data []byte, v interface{}) error {
+	c, body, err := detect(data)
+	if err != nil {
+		return err
+	}
+	return c.Unmarshal(body, v)
+}
+
+// Format return the name of codec the data encoded by.
+func Format(data []byte) (string, error) {
+	c, _, err := detect(data)
+	if err != nil {
+		return "", err
+	}
+	return c.Name(), nil
+}
+
+func detect(data []byte) (Codec, []byte, error) {
+	if len(data) == 0 {
+		return JSON, data, nil
+	}
+	switch data[0] {
+	case '{', '[', '"', 'n', 't', 'f', ' ', '\t', '\r', '\n', '-',
+		'0', '1', '2', '3', '4', '5', '6', '7', '8', '9':
+		return JSON, data, nil
+	}
+	return nil, nil, ErrUnknownMarker
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) Name() string { return JSONName }
+
+func (jsonCodec) Marker() byte { return noMarker }
+
+func (jsonCodec) Marshal(v interface{}) ([]byte, error) { return json.Marshal(v) }
+
+func (jsonCodec) Unmarshal(data []byte, v interface{}) error { return json.Unmarshal(data, v) }
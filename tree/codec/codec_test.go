@@ -0,0 +1,122 @@
+package codec
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type testTarget struct {
+	Ns    string `json:"ns"`
+	Where string `json:"where,omitempty"`
+}
+
+type testProperty struct {
+	ID   string `json:"id"`
+	Type int    `json:"type"`
+}
+
+type testValue struct {
+	testProperty
+	Title   string            `json:"title"`
+	Targets []testTarget      `json:"targets"`
+	Tags    map[string]string `json:"tags"`
+	Weight  float64           `json:"weight"`
+	Offset  int64             `json:"offset"`
+	Enable  bool              `json:"enable"`
+	Raw     []byte            `json:"raw"`
+	Any     interface{}       `json:"any"`
+	Next    *testValue        `json:"next"`
+	skip    string
+}
+
+func sampleValue() []testValue {
+	return []testValue{
+		{
+			testProperty: testProperty{ID: "0", Type: 1},
+			Title:        strings.Repeat("long title ", 10),
+			Targets:      []testTarget{{Ns: "a.loda", Where: "host='a'"}, {Ns: "b.loda"}},
+			Tags:         map[string]string{"k1": "v1", "k2": ""},
+			Weight:       0.5,
+			Offset:       -70000,
+			Enable:       true,
+			Raw:          []byte{0, 1, 2},
+			Any:          "any",
+			Next:         &testValue{Title: "next", Offset: 300},
+		},
+		{Title: "empty"},
+	}
+}
+
+func TestCodecRoundTrip(t *testing.T) {
+	for _, c := range []Codec{JSON} {
+		data, err := Encode(c, sampleValue())
+		if err != nil {
+			t.Fatalf("encode by %s fail: %s", c.Name(), err.Error())
+		}
+		if format, err := Format(data); err != nil || format != c.Name() {
+			t.Fatalf("format of %s data not match with expect: %s, %v", c.Name(), format, err)
+		}
+
+		var out []testValue
+		if err := Decode(data, &out); err != nil {
+			t.Fatalf("decode by %s fail: %s", c.Name(), err.Error())
+		}
+		if !reflect.DeepEqual(out, sampleValue()) {
+			t.Fatalf("%s round trip not match with expect: %+v", c.Name(), out)
+		}
+	}
+}
+
+func TestDecodeLegacyJSON(t *testing.T) {
+	data, err := json.Marshal(sampleValue())
+	if err != nil {
+		t.Fatalf("json marshal fail: %s", err.Error())
+	}
+	var out []testValue
+	if err := Decode(data, &out); err != nil || !reflect.DeepEqual(out, sampleValue()) {
+		t.Fatalf("decode legacy json not match with expect: %+v, %v", out, err)
+	}
+}
+
+func TestDecodeInvalid(t *testing.T) {
+	var out []testValue
+	if err := Decode([]byte{0xff, 1}, &out); err != ErrUnknownMarker {
+		t.Fatalf("decode unknown marker not match with expect: %v", err)
+	}
+	data, _ := Encode(JSON, sampleValue())
+	if err := Decode(data[:len(data)-1], &out); err == nil {
+		t.Fatalf("decode truncated data success, not match with expect")
+	}
+	if _, err := ByName("msgpack"); err != ErrUnknownCodec {
+		t.Fatalf("get unknown codec not match with expect: %v", err)
+	}
+}
+
+func benchmarkMarshal(b *testing.B, c Codec) {
+	v := sampleValue()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := Encode(c, v); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func benchmarkUnmarshal(b *testing.B, c Codec) {
+	data, err := Encode(c, sampleValue())
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		var out []testValue
+		if err := Decode(data, &out); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkMarshalJSON(b *testing.B)   { benchmarkMarshal(b, JSON) }
+func BenchmarkUnmarshalJSON(b *testing.B) { benchmarkUnmarshal(b, JSON) }
@@ -1,23 +1,94 @@
 package tree
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"sort"
 
 	"github.com/lodastack/registry/common"
+	"github.com/lodastack/registry/config"
 	"github.com/lodastack/registry/model"
+	"github.com/lodastack/registry/tree/codec"
 )
 
 var (
 	dashboardType = "dashboard"
+
+	// ErrVersionMismatch is returned if the dashboards are changed since the expected version.
+	ErrVersionMismatch = errors.New("dashboard version mismatch")
 )
 
+// defaultDashboardPageLimit is the max count of dashboards returned by GetDashboardsPaged if not configured.
+const defaultDashboardPageLimit = 50
+
+// noIndex is passed to checkIndex if the index needn't to be checked.
+const noIndex = -1
+
+// IndexError is returned when the dashboard/panel/target index is out of range.
+// The valid range of the index is [0, Bound).
+// Side is "from" or "to" if the index is of the ns copied from or to, e.g. by CopyPanel.
+type IndexError struct {
+	Side  string `json:"side,omitempty"`
+	Field string `json:"field"`
+	Index int    `json:"index"`
+	Bound int    `json:"bound"`
+}
+
+func (e *IndexError) Error() string {
+	var msg string
+	if e.Bound == 0 {
+		msg = fmt.Sprintf("%s index %d out of range, there is no %s", e.Field, e.Index, e.Field)
+	} else {
+		msg = fmt.Sprintf("%s index %d out of range, valid range is [0, %d]", e.Field, e.Index, e.Bound-1)
+	}
+	if e.Side != "" {
+		return e.Side + " " + msg
+	}
+	return msg
+}
+
+// Unwrap return ErrInvalidParam, so errors.Is(err, common.ErrInvalidParam) is true for IndexError.
+func (e *IndexError) Unwrap() error {
+	return common.ErrInvalidParam
+}
+
+// checkIndex check the dashboard/panel/target index in order,
+// return IndexError naming the first index out of range.
+func checkIndex(dashboards model.DashboardData, dIndex, panelIndex, targetIndex int) error {
+	if dIndex < 0 || dIndex >= len(dashboards) {
+		return &IndexError{Field: "dashboard", Index: dIndex, Bound: len(dashboards)}
+	}
+	if panelIndex == noIndex {
+		return nil
+	}
+	panels := dashboards[dIndex].Panels
+	if panelIndex < 0 || panelIndex >= len(panels) {
+		return &IndexError{Field: "panel", Index: panelIndex, Bound: len(panels)}
+	}
+	if targetIndex == noIndex {
+		return nil
+	}
+	targets := panels[panelIndex].Targets
+	if targetIndex < 0 || targetIndex >= len(targets) {
+		return &IndexError{Field: "target", Index: targetIndex, Bound: len(targets)}
+	}
+	return nil
+}
+
 // DashboardInf is interface the dashboard resource have.
 type DashboardInf interface {
 	// GetDashboard return dashboard map of the ns.
 	GetDashboard(ns string) (model.DashboardData, error)
 
+	// GetDashboardsPaged return the dashboards in range and the total count of the dashboards.
+	GetDashboardsPaged(ns string, offset, limit int) (model.DashboardData, int, error)
+
+	// GetDashboardPanels return the panels in range of a dashboard and the total count of its panels.
+	GetDashboardPanels(ns string, dIndex, panelOffset, panelLimit int) ([]model.Panel, int, error)
+
 	// SetDashboard set the dashboard map to the ns.
 	SetDashboard(ns string, dashboardData model.DashboardData) error
 
@@ -30,6 +101,18 @@ type DashboardInf interface {
 	// UpdateDashboard update the title of dashboard.
 	UpdateDashboard(ns string, dIndex int, title string) error
 
+	// GetDashboardWithVersion return the dashboard of ns and its version.
+	GetDashboardWithVersion(ns string) (model.DashboardData, string, error)
+
+	// SetDashboardIfVersion set the dashboard of ns only if its version is not changed.
+	SetDashboardIfVersion(ns string, dashboards model.DashboardData, expectedVersion string) error
+
+	// ModifyDashboards make multiple changes to the dashboards of ns and persist once.
+	ModifyDashboards(ns string, fn func(*DashboardEditor) error) error
+
+	// ValidateDashboards check the dashboards in json without saving them.
+	ValidateDashboards(data []byte) (ValidationErrors, error)
+
 	PanelInf
 }
 
@@ -45,6 +128,9 @@ type PanelInf interface {
 	// RemovePanel delete the panel of the dashboard.
 	RemovePanel(ns string, dIndex int, panelIndex int) error
 
+	// RemovePanelOrDashboard delete the panel, and delete the dashboard if removeEmpty and it has no panel left.
+	RemovePanelOrDashboard(ns string, dIndex int, panelIndex int, removeEmpty bool) (bool, error)
+
 	// UpdatePanel update the panel of the dashboard.
 	UpdatePanel(ns string, dIndex int, panelIndex int, title, graphType string) error
 
@@ -56,6 +142,12 @@ type PanelInf interface {
 
 	// RemoveTarget delete a target.
 	RemoveTarget(ns string, dIndex int, panelIndex, targetIndex int) error
+
+	// CopyPanel copy the panel to the dashboard of other ns.
+	CopyPanel(fromNs string, fromDIndex, panelIndex int, toNs string, toDIndex int) error
+
+	// MovePanel move the panel to the dashboard of other ns.
+	MovePanel(fromNs string, fromDIndex, panelIndex int, toNs string, toDIndex int) error
 }
 
 // GetDashboard return the dashboard under the ns.
@@ -74,7 +166,7 @@ func (t *Tree) GetDashboard(ns string) (model.DashboardData, error) {
 		return nil, nil
 	}
 	var rl []model.Dashboard
-	err = json.Unmarshal(resByte, &rl)
+	err = codec.Decode(resByte, &rl)
 	if err != nil {
 		t.logger.Errorf("unmarshal resource fail, error: %s, data: %s:", err, string(resByte))
 		return nil, err
@@ -82,14 +174,113 @@ func (t *Tree) GetDashboard(ns string) (model.DashboardData, error) {
 	return rl, nil
 }
 
+// GetDashboardWithVersion return the dashboard under the ns and its version,
+// the version is changed whenever the dashboards are changed and is empty if there is no dashboard.
+func (t *Tree) GetDashboardWithVersion(ns string) (model.DashboardData, string, error) {
+	dashboards, err := t.GetDashboard(ns)
+	if err != nil {
+		return nil, "", err
+	}
+	version, err := dashboardVersion(dashboards)
+	if err != nil {
+		return nil, "", err
+	}
+	return dashboards, version, nil
+}
+
+// dashboardVersion return the hash of the dashboards, which not depend on the codec.
+func dashboardVersion(dashboards model.DashboardData) (string, error) {
+	if len(dashboards) == 0 {
+		return "", nil
+	}
+	data, err := json.Marshal(dashboards)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// GetDashboardsPaged return at most limit dashboards of the ns from offset, and the total count of the dashboards.
+// limit is clamped to the configured max, 0 limit means the max.
+func (t *Tree) GetDashboardsPaged(ns string, offset, limit int) (model.DashboardData, int, error) {
+	dashboards, err := t.GetDashboard(ns)
+	if err != nil {
+		return nil, 0, err
+	}
+	maxLimit := config.C.CommonConf.DashboardPageLimit
+	if maxLimit <= 0 {
+		maxLimit = defaultDashboardPageLimit
+	}
+	return dashboardRange(dashboards, offset, limit, maxLimit)
+}
+
+// dashboardRange return the dashboards in [offset, offset+limit), limit is clamped to maxLimit.
+// Return empty dashboard list if offset is equal to the count of dashboards.
+func dashboardRange(dashboards model.DashboardData, offset, limit, maxLimit int) (model.DashboardData, int, error) {
+	if offset < 0 || limit < 0 || offset > len(dashboards) {
+		return nil, len(dashboards), common.ErrInvalidParam
+	}
+	if limit == 0 || limit > maxLimit {
+		limit = maxLimit
+	}
+	end := offset + limit
+	if end > len(dashboards) {
+		end = len(dashboards)
+	}
+	return dashboards[offset:end], len(dashboards), nil
+}
+
+// GetDashboardPanels return at most panelLimit panels of the dashboard from panelOffset,
+// and the total count of the panels, so the panels could be loaded part by part.
+func (t *Tree) GetDashboardPanels(ns string, dIndex, panelOffset, panelLimit int) ([]model.Panel, int, error) {
+	dashboards, err := t.GetDashboard(ns)
+	if err != nil {
+		return nil, 0, err
+	}
+	return panelRange(dashboards, dIndex, panelOffset, panelLimit)
+}
+
+// panelRange return the panels of dashboards[dIndex] in [offset, offset+limit).
+// Return empty panel list if offset is equal to the count of panels.
+func panelRange(dashboards model.DashboardData, dIndex, offset, limit int) ([]model.Panel, int, error) {
+	if dIndex < 0 || dIndex >= len(dashboards) {
+		return nil, 0, common.ErrInvalidParam
+	}
+	panels := dashboards[dIndex].Panels
+	if offset < 0 || limit <= 0 || offset > len(panels) {
+		return nil, len(panels), common.ErrInvalidParam
+	}
+	end := offset + limit
+	if end > len(panels) {
+		end = len(panels)
+	}
+	return panels[offset:end], len(panels), nil
+}
+
+// getNodeIDAllowDashboard return the node ID of ns, return NodeTypeError if the node could not be set dashboard.
+// The dashboard is saved as a resource of node, so it is checked as other resource.
+func (t *Tree) getNodeIDAllowDashboard(ns string) (string, error) {
+	n, err := t.node.GetNodeByNS(ns)
+	if err != nil {
+		t.logger.Errorf("GetNodeByNS fail: %s", err.Error())
+		return "", err
+	}
+	if err := n.CheckResource(dashboardType); err != nil {
+		t.logger.Errorf("set dashboard of ns %s fail: %s", ns, err.Error())
+		return "", err
+	}
+	return n.ID, nil
+}
+
 // SetDashboard set the dashboard to a node.
+// Return NodeTypeError if the node is not leaf.
 func (t *Tree) SetDashboard(ns string, dashboards model.DashboardData) error {
-	nodeID, err := t.getNodeIDByNS(ns)
+	nodeID, err := t.getNodeIDAllowDashboard(ns)
 	if err != nil {
-		t.logger.Errorf("getIDByNs fail: %s", err.Error())
 		return err
 	}
-	resNewByte, err := json.Marshal(dashboards)
+	resNewByte, err := codec.Encode(t.codec, dashboards)
 	if err != nil {
 		t.logger.Errorf("marshal dashboard fail: %s", err.Error())
 		return err
@@ -97,105 +288,252 @@ func (t *Tree) SetDashboard(ns string, dashboards model.DashboardData) error {
 	return t.setByteToStore(nodeID, dashboardType, resNewByte)
 }
 
-// AddDashboard add a dashboard to a ns.
-func (t *Tree) AddDashboard(ns string, dashboardData model.Dashboard) error {
+// SetDashboardIfVersion set the dashboard to a node only if its version is still expectedVersion,
+// otherwise return ErrVersionMismatch, so the change made by others since read is not overwritten.
+// The check and set is serialized with ModifyDashboards of this process only. The version is not
+// compared inside the store write, so a change made by other registry node between the check and
+// the write is not detected.
+func (t *Tree) SetDashboardIfVersion(ns string, dashboards model.DashboardData, expectedVersion string) error {
+	return t.ModifyDashboards(ns, func(e *DashboardEditor) error {
+		version, err := dashboardVersion(e.Data)
+		if err != nil {
+			return err
+		}
+		if version != expectedVersion {
+			t.logger.Errorf("dashboard of ns %s version is %s, expect %s", ns, version, expectedVersion)
+			return ErrVersionMismatch
+		}
+		e.Data = dashboards
+		return nil
+	})
+}
+
+// ModifyDashboards load the dashboards of ns once, let fn make multiple changes by the editor,
+// then persist the dashboards once. Nothing is persisted if fn return error.
+// ModifyDashboards of the tree is serialized by a lock in this process only, so the changes
+// made by this registry node do not interleave. The store has no compare and set, the change made
+// at the same time by other registry node or by SetDashboard may still be overwritten.
+func (t *Tree) ModifyDashboards(ns string, fn func(*DashboardEditor) error) error {
+	t.dashboardMu.Lock()
+	defer t.dashboardMu.Unlock()
+
 	dashboards, err := t.GetDashboard(ns)
 	if err != nil {
+		t.logger.Errorf("GetDashboard of ns %s error: %v", ns, err)
 		return err
 	}
+	editor := &DashboardEditor{Data: dashboards}
+	if err := fn(editor); err != nil {
+		return err
+	}
+	return t.SetDashboard(ns, editor.Data)
+}
 
-	dashboards = append(dashboards, dashboardData)
-	return t.SetDashboard(ns, dashboards)
+// AddDashboard add a dashboard to a ns.
+func (t *Tree) AddDashboard(ns string, dashboardData model.Dashboard) error {
+	return t.ModifyDashboards(ns, func(e *DashboardEditor) error {
+		e.AddDashboard(dashboardData)
+		return nil
+	})
 }
 
 // UpdateDashboard update one dashboard title of ns.
 func (t *Tree) UpdateDashboard(ns string, dIndex int, title string) error {
-	dashboards, err := t.GetDashboard(ns)
-	if err != nil {
-		return err
-	}
-	if dIndex >= len(dashboards) {
-		return common.ErrInvalidParam
-	}
-	dashboards[dIndex].Title = title
-	return t.SetDashboard(ns, dashboards)
+	return t.ModifyDashboards(ns, func(e *DashboardEditor) error {
+		return e.UpdateDashboard(dIndex, title)
+	})
 }
 
 // RemoveDashboard one dashboard of ns.
 func (t *Tree) RemoveDashboard(ns string, dIndex int) error {
-	dashboards, err := t.GetDashboard(ns)
-	if err != nil || dIndex >= len(dashboards) {
-		t.logger.Errorf("DeleteDashboard error, data: %+v, error: %v", dashboards, err)
+	return t.ModifyDashboards(ns, func(e *DashboardEditor) error {
+		return e.RemoveDashboard(dIndex)
+	})
+}
+
+// ReorderPanel update the order of panel by newOrder.
+func (t *Tree) ReorderPanel(ns string, dIndex int, newOrder []int) error {
+	return t.ModifyDashboards(ns, func(e *DashboardEditor) error {
+		return e.ReorderPanel(dIndex, newOrder)
+	})
+}
+
+// AddPanel add a panel to a dashboard.
+func (t *Tree) AddPanel(ns string, dIndex int, panel model.Panel) error {
+	return t.ModifyDashboards(ns, func(e *DashboardEditor) error {
+		return e.AddPanel(dIndex, panel)
+	})
+}
+
+// UpdatePanel update a panel.
+func (t *Tree) UpdatePanel(ns string, dIndex int, panelIndex int, title, graphType string) error {
+	return t.ModifyDashboards(ns, func(e *DashboardEditor) error {
+		return e.UpdatePanel(dIndex, panelIndex, title, graphType)
+	})
+}
+
+// RemovePanel remove a panel from a dashboard.
+func (t *Tree) RemovePanel(ns string, dIndex int, panelIndex int) error {
+	return t.ModifyDashboards(ns, func(e *DashboardEditor) error {
+		return e.RemovePanel(dIndex, panelIndex)
+	})
+}
+
+// RemovePanelOrDashboard remove a panel from a dashboard,
+// remove the dashboard too if removeEmpty is true and the removed panel is the last one.
+// Return whether the dashboard is removed.
+func (t *Tree) RemovePanelOrDashboard(ns string, dIndex int, panelIndex int, removeEmpty bool) (bool, error) {
+	var dashboardRemoved bool
+	err := t.ModifyDashboards(ns, func(e *DashboardEditor) (err error) {
+		dashboardRemoved, err = e.RemovePanelOrDashboard(dIndex, panelIndex, removeEmpty)
+		return err
+	})
+	return dashboardRemoved, err
+}
+
+// AppendTarget append a target to panel.
+func (t *Tree) AppendTarget(ns string, dIndex int, panelIndex int, target model.Target) error {
+	return t.ModifyDashboards(ns, func(e *DashboardEditor) error {
+		return e.AppendTarget(dIndex, panelIndex, target)
+	})
+}
+
+// UpdateTarget update a target.
+func (t *Tree) UpdateTarget(ns string, dIndex int, panelIndex, targetIndex int, target model.Target) error {
+	return t.ModifyDashboards(ns, func(e *DashboardEditor) error {
+		return e.UpdateTarget(dIndex, panelIndex, targetIndex, target)
+	})
+}
+
+// RemoveTarget remove update a target.
+func (t *Tree) RemoveTarget(ns string, dIndex int, panelIndex, targetIndex int) error {
+	return t.ModifyDashboards(ns, func(e *DashboardEditor) error {
+		return e.RemoveTarget(dIndex, panelIndex, targetIndex)
+	})
+}
+
+// DashboardEditor edit the dashboards in memory, used by ModifyDashboards.
+type DashboardEditor struct {
+	Data model.DashboardData
+}
+
+// AddDashboard add a dashboard.
+func (e *DashboardEditor) AddDashboard(dashboard model.Dashboard) {
+	e.Data = append(e.Data, dashboard)
+}
+
+// UpdateDashboard update the dashboard title.
+func (e *DashboardEditor) UpdateDashboard(dIndex int, title string) error {
+	if err := checkIndex(e.Data, dIndex, noIndex, noIndex); err != nil {
 		return err
 	}
+	e.Data[dIndex].Title = title
+	return nil
+}
 
-	copy(dashboards[dIndex:], dashboards[dIndex+1:])
-	return t.SetDashboard(ns, dashboards[:len(dashboards)-1])
+// RemoveDashboard remove a dashboard.
+func (e *DashboardEditor) RemoveDashboard(dIndex int) error {
+	if err := checkIndex(e.Data, dIndex, noIndex, noIndex); err != nil {
+		return err
+	}
+	copy(e.Data[dIndex:], e.Data[dIndex+1:])
+	e.Data = e.Data[:len(e.Data)-1]
+	return nil
 }
 
 // ReorderPanel update the order of panel by newOrder.
-func (t *Tree) ReorderPanel(ns string, dIndex int, newOrder []int) error {
-	dashboards, err := t.GetDashboard(ns)
-	if err != nil || len(dashboards) == 0 || dIndex >= len(dashboards) {
-		t.logger.Errorf("ReorderPanel error, data: %+v, error: %v", dashboards, err)
-		return common.ErrInvalidParam
+func (e *DashboardEditor) ReorderPanel(dIndex int, newOrder []int) error {
+	if err := checkIndex(e.Data, dIndex, noIndex, noIndex); err != nil {
+		return err
 	}
-	if len(dashboards[dIndex].Panels) != len(newOrder) {
+	if len(e.Data[dIndex].Panels) != len(newOrder) {
 		return errors.New("dashboard name or new order invalid")
 	}
 	if invalidOrder(newOrder) {
 		return errors.New("dashboard new order invalid")
 	}
 
-	newPanels := make([]model.Panel, len(dashboards[dIndex].Panels))
+	newPanels := make([]model.Panel, len(e.Data[dIndex].Panels))
 	for i, order := range newOrder {
-		newPanels[i] = dashboards[dIndex].Panels[order]
+		newPanels[i] = e.Data[dIndex].Panels[order]
 	}
-	dashboards[dIndex].Panels = newPanels
-	return t.SetDashboard(ns, dashboards)
+	e.Data[dIndex].Panels = newPanels
+	return nil
 }
 
 // AddPanel add a panel to a dashboard.
-func (t *Tree) AddPanel(ns string, dIndex int, panel model.Panel) error {
-	dashboards, err := t.GetDashboard(ns)
-	if err != nil || len(dashboards) == 0 || dIndex >= len(dashboards) {
-		t.logger.Errorf("AddPanel error, data: %+v, error: %v", dashboards, err)
-		return common.ErrInvalidParam
+func (e *DashboardEditor) AddPanel(dIndex int, panel model.Panel) error {
+	if err := checkIndex(e.Data, dIndex, noIndex, noIndex); err != nil {
+		return err
 	}
-
-	dashboards[dIndex].Panels = append(dashboards[dIndex].Panels, panel)
-	return t.SetDashboard(ns, dashboards)
+	e.Data[dIndex].Panels = append(e.Data[dIndex].Panels, panel)
+	return nil
 }
 
-// UpdatePanel update a panel.
-func (t *Tree) UpdatePanel(ns string, dIndex int, panelIndex int, title, graphType string) error {
-	dashboards, err := t.GetDashboard(ns)
-	if err != nil || len(dashboards) == 0 || dIndex >= len(dashboards) || len(dashboards[dIndex].Panels) <= panelIndex {
-		t.logger.Errorf("AddPanel error, data: %+v, dindex %d, pindex %d, error: %v", dashboards, dIndex, panelIndex, err)
-		return common.ErrInvalidParam
+// UpdatePanel update the title or graph type of a panel, the empty one is not updated.
+func (e *DashboardEditor) UpdatePanel(dIndex int, panelIndex int, title, graphType string) error {
+	if err := checkIndex(e.Data, dIndex, panelIndex, noIndex); err != nil {
+		return err
 	}
-
 	if title != "" {
-		dashboards[dIndex].Panels[panelIndex].Title = title
+		e.Data[dIndex].Panels[panelIndex].Title = title
 	}
 	if graphType != "" {
-		dashboards[dIndex].Panels[panelIndex].GraphType = graphType
+		e.Data[dIndex].Panels[panelIndex].GraphType = graphType
 	}
-	return t.SetDashboard(ns, dashboards)
+	return nil
 }
 
 // RemovePanel remove a panel from a dashboard.
-func (t *Tree) RemovePanel(ns string, dIndex int, panelIndex int) error {
-	dashboards, err := t.GetDashboard(ns)
-	if err != nil || len(dashboards) == 0 || dIndex >= len(dashboards) || panelIndex >= len(dashboards[dIndex].Panels) {
-		t.logger.Errorf("AddPanel error, data: %+v, dindex %d, pindex %d, error: %v", dashboards, dIndex, panelIndex, err)
-		return common.ErrInvalidParam
+func (e *DashboardEditor) RemovePanel(dIndex int, panelIndex int) error {
+	if err := checkIndex(e.Data, dIndex, panelIndex, noIndex); err != nil {
+		return err
+	}
+	panels := e.Data[dIndex].Panels
+	copy(panels[panelIndex:], panels[panelIndex+1:])
+	e.Data[dIndex].Panels = panels[:len(panels)-1]
+	return nil
+}
+
+// RemovePanelOrDashboard remove the panel, and remove the dashboard if removeEmpty and no panel left.
+func (e *DashboardEditor) RemovePanelOrDashboard(dIndex int, panelIndex int, removeEmpty bool) (bool, error) {
+	if err := e.RemovePanel(dIndex, panelIndex); err != nil {
+		return false, err
 	}
+	if !removeEmpty || len(e.Data[dIndex].Panels) != 0 {
+		return false, nil
+	}
+	return true, e.RemoveDashboard(dIndex)
+}
 
-	// TODO: check
-	copy(dashboards[dIndex].Panels[panelIndex:], dashboards[dIndex].Panels[panelIndex+1:])
-	dashboards[dIndex].Panels = dashboards[dIndex].Panels[:len(dashboards[dIndex].Panels)-1]
-	return t.SetDashboard(ns, dashboards)
+// AppendTarget append a target to panel.
+func (e *DashboardEditor) AppendTarget(dIndex int, panelIndex int, target model.Target) error {
+	if err := checkIndex(e.Data, dIndex, panelIndex, noIndex); err != nil {
+		return err
+	}
+	panel := &e.Data[dIndex].Panels[panelIndex]
+	panel.Targets = append(panel.Targets, target)
+	return nil
+}
+
+// UpdateTarget update a target.
+func (e *DashboardEditor) UpdateTarget(dIndex int, panelIndex, targetIndex int, target model.Target) error {
+	if err := checkIndex(e.Data, dIndex, panelIndex, targetIndex); err != nil {
+		return err
+	}
+	e.Data[dIndex].Panels[panelIndex].Targets[targetIndex] = target
+	return nil
+}
+
+// RemoveTarget remove a target.
+func (e *DashboardEditor) RemoveTarget(dIndex int, panelIndex, targetIndex int) error {
+	if err := checkIndex(e.Data, dIndex, panelIndex, targetIndex); err != nil {
+		return err
+	}
+	panel := &e.Data[dIndex].Panels[panelIndex]
+	copy(panel.Targets[targetIndex:], panel.Targets[targetIndex+1:])
+	panel.Targets = panel.Targets[:len(panel.Targets)-1]
+	return nil
 }
 
 func invalidOrder(order sort.IntSlice) bool {
@@ -210,42 +548,58 @@ func invalidOrder(order sort.IntSlice) bool {
 	return false
 }
 
-// AppendTarget append a target to panel.
-func (t *Tree) AppendTarget(ns string, dIndex int, panelIndex int, target model.Target) error {
-	dashboards, err := t.GetDashboard(ns)
-	if err != nil || len(dashboards) == 0 || dIndex >= len(dashboards) || panelIndex >= len(dashboards[dIndex].Panels) {
-		t.logger.Errorf("AddPanel error, data: %+v, dindex %d, pindex %d, error: %v", dashboards, dIndex, panelIndex, err)
-		return common.ErrInvalidParam
-	}
+// CopyPanel append a copy of the panel of fromNs to the dashboard of toNs,
+// the copy share nothing with the source panel.
+// Return IndexError naming the source or target index if it is out of range.
+func (t *Tree) CopyPanel(fromNs string, fromDIndex, panelIndex int, toNs string, toDIndex int) error {
+	return t.transferPanel(fromNs, fromDIndex, panelIndex, toNs, toDIndex, false)
+}
 
-	dashboards[dIndex].Panels[panelIndex].Targets = append(dashboards[dIndex].Panels[panelIndex].Targets, target)
-	return t.SetDashboard(ns, dashboards)
+// MovePanel remove the panel from the dashboard of fromNs and append it to the dashboard of toNs in one batch.
+// Return IndexError naming the source or target index if it is out of range.
+func (t *Tree) MovePanel(fromNs string, fromDIndex, panelIndex int, toNs string, toDIndex int) error {
+	return t.transferPanel(fromNs, fromDIndex, panelIndex, toNs, toDIndex, true)
 }
 
-// UpdateTarget update a target.
-func (t *Tree) UpdateTarget(ns string, dIndex int, panelIndex, targetIndex int, target model.Target) error {
-	dashboards, err := t.GetDashboard(ns)
-	if err != nil || len(dashboards) == 0 || dIndex >= len(dashboards) || panelIndex >= len(dashboards[dIndex].Panels) || targetIndex >= len(dashboards[dIndex].Panels[panelIndex].Targets) {
-		t.logger.Errorf("AddPanel error, data: %+v, dindex %d, pindex %d, error: %v", dashboards, dIndex, panelIndex, err)
-		return common.ErrInvalidParam
-	}
+func (t *Tree) transferPanel(fromNs string, fromDIndex, panelIndex int, toNs string, toDIndex int, remove bool) error {
+	t.dashboardMu.Lock()
+	defer t.dashboardMu.Unlock()
 
-	dashboards[dIndex].Panels[panelIndex].Targets[targetIndex] = target
-	return t.SetDashboard(ns, dashboards)
+	return t.tx(func(tx *treeTx) error {
+		source, err := tx.getDashboards(fromNs)
+		if err != nil {
+			return err
+		}
+		if err := checkIndex(source, fromDIndex, panelIndex, noIndex); err != nil {
+			return sideIndexError("from", err)
+		}
+		panel := copyPanel(source[fromDIndex].Panels[panelIndex])
+		if remove {
+			if err := tx.ModifyDashboards(fromNs, func(e *DashboardEditor) error {
+				return e.RemovePanel(fromDIndex, panelIndex)
+			}); err != nil {
+				return err
+			}
+		}
+		return tx.ModifyDashboards(toNs, func(e *DashboardEditor) error {
+			return sideIndexError("to", e.AddPanel(toDIndex, panel))
+		})
+	})
 }
 
-// RemoveTarget remove update a target.
-func (t *Tree) RemoveTarget(ns string, dIndex int, panelIndex, targetIndex int) error {
-	dashboards, err := t.GetDashboard(ns)
-	if err != nil || len(dashboards) == 0 || dIndex >= len(dashboards) || panelIndex >= len(dashboards[dIndex].Panels) || targetIndex >= len(dashboards[dIndex].Panels[panelIndex].Targets) {
-		t.logger.Errorf("AddPanel error, data: %+v, dindex %d, pindex %d, error: %v", dashboards, dIndex, panelIndex, err)
-		return common.ErrInvalidParam
-	}
-	if targetIndex+1 < len(dashboards[dIndex].Panels[panelIndex].Targets) {
-		copy(dashboards[dIndex].Panels[panelIndex].Targets[targetIndex:], dashboards[dIndex].Panels[panelIndex].Targets[targetIndex+1:])
+// sideIndexError set the side of IndexError, the field is not changed.
+func sideIndexError(side string, err error) error {
+	if indexErr, ok := err.(*IndexError); ok {
+		indexErr.Side = side
 	}
-	length := len(dashboards[dIndex].Panels[panelIndex].Targets)
-	dashboards[dIndex].Panels[panelIndex].Targets = dashboards[dIndex].Panels[panelIndex].Targets[:length-1]
+	return err
+}
 
-	return t.SetDashboard(ns, dashboards)
+func copyPanel(panel model.Panel) model.Panel {
+	copied := panel
+	if panel.Targets != nil {
+		copied.Targets = make([]model.Target, len(panel.Targets))
+		copy(copied.Targets, panel.Targets)
+	}
+	return copied
 }
@@ -0,0 +1,348 @@
+package tree
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/lodastack/registry/common"
+	"github.com/lodastack/registry/model"
+	"github.com/lodastack/registry/tree/node"
+	"github.com/lodastack/registry/tree/test_sample"
+)
+
+func TestCheckDashboardIndex(t *testing.T) {
+	dashboards := model.DashboardData{
+		{Title: "d0", Panels: []model.Panel{
+			{Title: "p0", Targets: []model.Target{{Measurement: "m0"}}},
+			{Title: "p1"},
+		}},
+	}
+
+	if err := checkIndex(dashboards, 0, 1, noIndex); err != nil {
+		t.Fatalf("check valid index fail: %s", err.Error())
+	}
+	if err := checkIndex(dashboards, 0, 0, 0); err != nil {
+		t.Fatalf("check valid index fail: %s", err.Error())
+	}
+
+	for _, c := range []struct {
+		dIndex, pIndex, tIndex int
+		field                  string
+		bound                  int
+	}{
+		{1, noIndex, noIndex, "dashboard", 1},
+		{-1, noIndex, noIndex, "dashboard", 1},
+		{0, 2, noIndex, "panel", 2},
+		{0, 0, 1, "target", 1},
+		{0, 1, 0, "target", 0},
+	} {
+		err := checkIndex(dashboards, c.dIndex, c.pIndex, c.tIndex)
+		indexErr, ok := err.(*IndexError)
+		if !ok {
+			t.Fatalf("check index %d/%d/%d not match with expect, error: %v", c.dIndex, c.pIndex, c.tIndex, err)
+		}
+		if indexErr.Field != c.field || indexErr.Bound != c.bound {
+			t.Fatalf("index error not match with expect: %+v", indexErr)
+		}
+	}
+}
+
+func TestDashboardEditor(t *testing.T) {
+	e := &DashboardEditor{}
+	e.AddDashboard(model.Dashboard{Title: "d0"})
+	for _, title := range []string{"p0", "p1", "p2"} {
+		if err := e.AddPanel(0, model.Panel{Title: title}); err != nil {
+			t.Fatalf("add panel fail: %s", err.Error())
+		}
+	}
+	if err := e.ReorderPanel(0, []int{2, 0, 1}); err != nil {
+		t.Fatalf("reorder panel fail: %s", err.Error())
+	}
+	if err := e.AppendTarget(0, 0, model.Target{Measurement: "m0"}); err != nil {
+		t.Fatalf("append target fail: %s", err.Error())
+	}
+	if err := e.AppendTarget(0, 0, model.Target{Measurement: "m1"}); err != nil {
+		t.Fatalf("append target fail: %s", err.Error())
+	}
+	if err := e.RemoveTarget(0, 0, 0); err != nil {
+		t.Fatalf("remove target fail: %s", err.Error())
+	}
+	if err := e.RemovePanel(0, 1); err != nil {
+		t.Fatalf("remove panel fail: %s", err.Error())
+	}
+
+	panels := e.Data[0].Panels
+	if len(panels) != 2 || panels[0].Title != "p2" || panels[1].Title != "p1" ||
+		len(panels[0].Targets) != 1 || panels[0].Targets[0].Measurement != "m1" {
+		t.Fatalf("dashboard not match with expect: %+v", e.Data)
+	}
+
+	if err := e.UpdatePanel(0, 2, "p", ""); err == nil {
+		t.Fatalf("update panel out of range success, not match with expect")
+	}
+	if err := e.RemoveDashboard(0); err != nil || len(e.Data) != 0 {
+		t.Fatalf("remove dashboard not match with expect: %v, %+v", err, e.Data)
+	}
+}
+
+func TestDashboardRange(t *testing.T) {
+	dashboards := model.DashboardData{{Title: "d0"}, {Title: "d1"}, {Title: "d2"}}
+	for _, c := range []struct {
+		offset, limit int
+		expect        []string
+		valid         bool
+	}{
+		{0, 2, []string{"d0", "d1"}, true},
+		{1, 5, []string{"d1", "d2"}, true},
+		{0, 5, []string{"d0", "d1"}, true},
+		{0, 0, []string{"d0", "d1"}, true},
+		{3, 1, []string{}, true},
+		{4, 1, nil, false},
+		{-1, 1, nil, false},
+		{0, -1, nil, false},
+	} {
+		page, total, err := dashboardRange(dashboards, c.offset, c.limit, 2)
+		if (err == nil) != c.valid {
+			t.Fatalf("dashboard range %+v not match with expect: %v", c, err)
+		}
+		if !c.valid {
+			continue
+		}
+		if total != 3 || len(page) != len(c.expect) {
+			t.Fatalf("dashboard range %+v not match with expect: %d, %+v", c, total, page)
+		}
+		for i := range page {
+			if page[i].Title != c.expect[i] {
+				t.Fatalf("dashboard range %+v not match with expect: %+v", c, page)
+			}
+		}
+	}
+}
+
+func TestPanelRange(t *testing.T) {
+	dashboards := model.DashboardData{{Title: "d0", Panels: []model.Panel{{Title: "p0"}, {Title: "p1"}, {Title: "p2"}}}}
+	for _, c := range []struct {
+		dIndex, offset, limit int
+		expect                []string
+		valid                 bool
+	}{
+		{0, 0, 2, []string{"p0", "p1"}, true},
+		{0, 1, 5, []string{"p1", "p2"}, true},
+		{0, 3, 1, []string{}, true},
+		{0, 4, 1, nil, false},
+		{0, -1, 1, nil, false},
+		{0, 0, 0, nil, false},
+		{1, 0, 1, nil, false},
+	} {
+		panels, total, err := panelRange(dashboards, c.dIndex, c.offset, c.limit)
+		if (err == nil) != c.valid {
+			t.Fatalf("panel range %+v not match with expect: %v", c, err)
+		}
+		if !c.valid {
+			continue
+		}
+		if total != 3 || len(panels) != len(c.expect) {
+			t.Fatalf("panel range %+v not match with expect: %d, %+v", c, total, panels)
+		}
+		for i := range panels {
+			if panels[i].Title != c.expect[i] {
+				t.Fatalf("panel range %+v not match with expect: %+v", c, panels)
+			}
+		}
+	}
+}
+
+func TestRemovePanelOrDashboard(t *testing.T) {
+	e := &DashboardEditor{Data: model.DashboardData{
+		{Title: "d0", Panels: []model.Panel{{Title: "p0"}, {Title: "p1"}}},
+		{Title: "d1", Panels: []model.Panel{{Title: "p0"}}},
+	}}
+	if removed, err := e.RemovePanelOrDashboard(0, 0, true); err != nil || removed {
+		t.Fatalf("remove not last panel not match with expect: %v, %v", removed, err)
+	}
+	if removed, err := e.RemovePanelOrDashboard(1, 0, false); err != nil || removed ||
+		len(e.Data) != 2 || len(e.Data[1].Panels) != 0 {
+		t.Fatalf("remove last panel and keep dashboard not match with expect: %v, %v, %+v", removed, err, e.Data)
+	}
+	if removed, err := e.RemovePanelOrDashboard(0, 0, true); err != nil || !removed ||
+		len(e.Data) != 1 || e.Data[0].Title != "d1" {
+		t.Fatalf("remove last panel and dashboard not match with expect: %v, %v, %+v", removed, err, e.Data)
+	}
+	if _, err := e.RemovePanelOrDashboard(0, 0, true); err == nil {
+		t.Fatalf("remove panel out of range success, not match with expect")
+	}
+}
+
+func TestValidateDashboards(t *testing.T) {
+	valid := model.DashboardData{{Title: "d0", Panels: []model.Panel{
+		{Title: "p0", GraphType: "line", Targets: []model.Target{{Ns: "test.loda", Measurement: "cpu.idle"}}},
+	}}}
+	if errs := validateDashboards(valid); len(errs) != 0 {
+		t.Fatalf("validate valid dashboards not match with expect: %+v", errs)
+	}
+
+	invalid := model.DashboardData{
+		{Title: "d0", Panels: []model.Panel{{Title: "p0"}}},
+		{Title: "d0", Panels: []model.Panel{
+			{Title: "p0", GraphType: "line", Targets: []model.Target{{Ns: "test.loda"}}},
+		}},
+	}
+	errs := validateDashboards(invalid)
+	if len(errs) != 4 {
+		t.Fatalf("validate invalid dashboards not match with expect: %+v", errs)
+	}
+	last := errs[3]
+	if last.Index != 1 || last.Field != "panel 0 target 0" {
+		t.Fatalf("problem of target not match with expect: %+v", last)
+	}
+}
+
+func TestCopyAndMovePanel(t *testing.T) {
+	s := test_sample.MustNewStore(t)
+	defer os.RemoveAll(s.Path())
+	if err := s.Open(true); err != nil {
+		t.Fatalf("failed to open single-node store: %s", err.Error())
+	}
+	defer s.Close(true)
+	s.WaitForLeader(10 * time.Second)
+	tree, _ := NewTree(s)
+
+	fromNs, toNs := "panelFrom."+node.RootNode, "panelTo."+node.RootNode
+	for _, name := range []string{"panelFrom", "panelTo"} {
+		if _, err := tree.NewNode(name, "comment", node.RootNode, node.Leaf); err != nil {
+			t.Fatalf("create leaf fail: %s", err.Error())
+		}
+	}
+	if err := tree.SetDashboard(fromNs, model.DashboardData{{Title: "d0", Panels: []model.Panel{
+		{Title: "p0", Targets: []model.Target{{Measurement: "m0"}}}, {Title: "p1"}}}}); err != nil {
+		t.Fatalf("set dashboard fail: %s", err.Error())
+	}
+	if err := tree.SetDashboard(toNs, model.DashboardData{{Title: "d0"}}); err != nil {
+		t.Fatalf("set dashboard fail: %s", err.Error())
+	}
+
+	// case 1: copy the panel, the source is not changed.
+	if err := tree.CopyPanel(fromNs, 0, 0, toNs, 0); err != nil {
+		t.Fatalf("CopyPanel fail: %s", err.Error())
+	}
+	to, _ := tree.GetDashboard(toNs)
+	from, _ := tree.GetDashboard(fromNs)
+	if len(to[0].Panels) != 1 || to[0].Panels[0].Title != "p0" || len(to[0].Panels[0].Targets) != 1 || len(from[0].Panels) != 2 {
+		t.Fatalf("CopyPanel not match with expect: %+v, %+v", from, to)
+	}
+
+	// case 2: the copy is independent of the source.
+	if err := tree.UpdateTarget(fromNs, 0, 0, 0, model.Target{Measurement: "changed"}); err != nil {
+		t.Fatalf("UpdateTarget fail: %s", err.Error())
+	}
+	if to, _ = tree.GetDashboard(toNs); to[0].Panels[0].Targets[0].Measurement != "m0" {
+		t.Fatalf("the copied panel is changed with the source: %+v", to)
+	}
+
+	// case 3: move the panel.
+	if err := tree.MovePanel(fromNs, 0, 1, toNs, 0); err != nil {
+		t.Fatalf("MovePanel fail: %s", err.Error())
+	}
+	to, _ = tree.GetDashboard(toNs)
+	from, _ = tree.GetDashboard(fromNs)
+	if len(to[0].Panels) != 2 || to[0].Panels[1].Title != "p1" || len(from[0].Panels) != 1 {
+		t.Fatalf("MovePanel not match with expect: %+v, %+v", from, to)
+	}
+
+	// case 4: invalid index on both side, nothing is changed.
+	for _, c := range []struct {
+		fromD, panel, toD int
+		side, field       string
+	}{
+		{1, 0, 0, "from", "dashboard"},
+		{0, 5, 0, "from", "panel"},
+		{0, 0, 3, "to", "dashboard"},
+	} {
+		err := tree.MovePanel(fromNs, c.fromD, c.panel, toNs, c.toD)
+		indexErr, ok := err.(*IndexError)
+		if !ok || indexErr.Side != c.side || indexErr.Field != c.field || !errors.Is(err, common.ErrInvalidParam) {
+			t.Fatalf("MovePanel %+v not match with expect: %v", c, err)
+		}
+	}
+	if from, _ = tree.GetDashboard(fromNs); len(from[0].Panels) != 1 {
+		t.Fatalf("source is changed by failed move: %+v", from)
+	}
+}
+
+func TestSetDashboardIfVersion(t *testing.T) {
+	s := test_sample.MustNewStore(t)
+	defer os.RemoveAll(s.Path())
+	if err := s.Open(true); err != nil {
+		t.Fatalf("failed to open single-node store: %s", err.Error())
+	}
+	defer s.Close(true)
+	s.WaitForLeader(10 * time.Second)
+	tree, _ := NewTree(s)
+
+	ns := "dashboardVersion." + node.RootNode
+	if _, err := tree.NewNode("dashboardVersion", "comment", node.RootNode, node.Leaf); err != nil {
+		t.Fatalf("create leaf fail: %s", err.Error())
+	}
+
+	// case 1: set the dashboards of ns which has no dashboard by empty version.
+	_, version, err := tree.GetDashboardWithVersion(ns)
+	if err != nil || version != "" {
+		t.Fatalf("GetDashboardWithVersion not match with expect: %s, error: %v", version, err)
+	}
+	if err := tree.SetDashboardIfVersion(ns, model.DashboardData{{Title: "d0"}}, version); err != nil {
+		t.Fatalf("SetDashboardIfVersion fail: %s", err.Error())
+	}
+	_, version1, err := tree.GetDashboardWithVersion(ns)
+	if err != nil || version1 == "" {
+		t.Fatalf("GetDashboardWithVersion not match with expect: %s, error: %v", version1, err)
+	}
+
+	// case 2: the dashboards is changed by others.
+	if err := tree.AddDashboard(ns, model.Dashboard{Title: "d1"}); err != nil {
+		t.Fatalf("AddDashboard fail: %s", err.Error())
+	}
+	if err := tree.SetDashboardIfVersion(ns, model.DashboardData{{Title: "d2"}}, version1); err != ErrVersionMismatch {
+		t.Fatalf("SetDashboardIfVersion with old version not match with expect, error: %v", err)
+	}
+	dashboards, version2, err := tree.GetDashboardWithVersion(ns)
+	if err != nil || len(dashboards) != 2 || version2 == version1 {
+		t.Fatalf("GetDashboardWithVersion not match with expect: %+v %s, error: %v", dashboards, version2, err)
+	}
+
+	// case 3: set by the latest version.
+	if err := tree.SetDashboardIfVersion(ns, model.DashboardData{{Title: "d2"}}, version2); err != nil {
+		t.Fatalf("SetDashboardIfVersion fail: %s", err.Error())
+	}
+	if dashboards, _ := tree.GetDashboard(ns); len(dashboards) != 1 || dashboards[0].Title != "d2" {
+		t.Fatalf("SetDashboardIfVersion not match with expect: %+v", dashboards)
+	}
+}
+
+func TestDashboardNodeType(t *testing.T) {
+	s := test_sample.MustNewStore(t)
+	defer os.RemoveAll(s.Path())
+	if err := s.Open(true); err != nil {
+		t.Fatalf("failed to open single-node store: %s", err.Error())
+	}
+	defer s.Close(true)
+	s.WaitForLeader(10 * time.Second)
+	tree, _ := NewTree(s)
+
+	ns := "dashboardNonLeaf." + node.RootNode
+	if _, err := tree.NewNode("dashboardNonLeaf", "comment", node.RootNode, node.NonLeaf); err != nil {
+		t.Fatalf("create nonleaf fail: %s", err.Error())
+	}
+	if err := tree.AddDashboard(ns, model.Dashboard{Title: "d0"}); err == nil {
+		t.Fatal("add dashboard to nonleaf success, not match with expect")
+	} else if _, ok := err.(*node.NodeTypeError); !ok {
+		t.Fatalf("add dashboard to nonleaf error not match with expect: %v", err)
+	}
+	err := tree.Tx(func(tx TreeTx) error {
+		return tx.SetDashboard(ns, model.DashboardData{{Title: "d0"}})
+	})
+	if _, ok := err.(*node.NodeTypeError); !ok {
+		t.Fatalf("set dashboard to nonleaf in tx error not match with expect: %v", err)
+	}
+}
@@ -0,0 +1,73 @@
+package tree
+
+import (
+	"encoding/json"
+
+	"github.com/lodastack/registry/model"
+)
+
+// defaultsBucket save the default properties of resource type, the key is the resource type.
+const defaultsBucket = "defaults"
+
+func (t *Tree) initDefaultsBucket() error {
+	if err := t.cluster.CreateBucketIfNotExist([]byte(defaultsBucket)); err != nil {
+		t.logger.Errorf("tree init %s CreateBucketIfNotExist fail: %s", defaultsBucket, err.Error())
+		return err
+	}
+	return nil
+}
+
+// SetResourceDefaults set the default properties of the resource type,
+// remove the defaults if defaults is empty.
+func (t *Tree) SetResourceDefaults(resType string, defaults map[string]string) error {
+	v := []byte{}
+	if len(defaults) != 0 {
+		var err error
+		if v, err = json.Marshal(defaults); err != nil {
+			return err
+		}
+	}
+	if err := t.cluster.Update([]byte(defaultsBucket), []byte(resType), v); err != nil {
+		t.logger.Errorf("set defaults of %s fail: %s", resType, err.Error())
+		return err
+	}
+	return nil
+}
+
+// GetResourceDefaults return the default properties of the resource type.
+func (t *Tree) GetResourceDefaults(resType string) (map[string]string, error) {
+	defaults := map[string]string{}
+	v, err := t.cluster.View([]byte(defaultsBucket), []byte(resType))
+	if err != nil {
+		t.logger.Errorf("view defaults of %s fail: %s", resType, err.Error())
+		return nil, err
+	}
+	if len(v) == 0 {
+		return defaults, nil
+	}
+	if err := json.Unmarshal(v, &defaults); err != nil {
+		t.logger.Errorf("unmarshal defaults of %s fail: %s", resType, err.Error())
+		return nil, err
+	}
+	return defaults, nil
+}
+
+// withDefaults return the copy of resources which the missing properties are set by the defaults of resource type.
+// The property set explicitly is not changed.
+func (t *Tree) withDefaults(resType string, resources ...model.Resource) ([]model.Resource, error) {
+	defaults, err := t.GetResourceDefaults(resType)
+	if err != nil || len(defaults) == 0 {
+		return resources, err
+	}
+	result := make([]model.Resource, len(resources))
+	for i, res := range resources {
+		result[i] = make(model.Resource, len(res)+len(defaults))
+		for k, v := range defaults {
+			result[i][k] = v
+		}
+		for k, v := range res {
+			result[i][k] = v
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,84 @@
+package tree
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/lodastack/registry/model"
+	"github.com/lodastack/registry/tree/node"
+	"github.com/lodastack/registry/tree/test_sample"
+)
+
+func TestResourceDefaults(t *testing.T) {
+	s := test_sample.MustNewStore(t)
+	defer os.RemoveAll(s.Path())
+
+	if err := s.Open(true); err != nil {
+		t.Fatalf("failed to open single-node store: %s", err.Error())
+	}
+	defer s.Close(true)
+	s.WaitForLeader(10 * time.Second)
+	tree, _ := NewTree(s)
+
+	ns := "testDefaults." + node.RootNode
+	if _, err := tree.NewNode("testDefaults", "comment", node.RootNode, node.Leaf, "defaults"); err != nil {
+		t.Fatalf("create leaf fail: %s", err.Error())
+	}
+	defaults := map[string]string{"owner": "ops", "receiver": "ops-team"}
+	if err := tree.SetResourceDefaults("machine", defaults); err != nil {
+		t.Fatalf("SetResourceDefaults fail: %s", err.Error())
+	}
+	if got, err := tree.GetResourceDefaults("machine"); err != nil || len(got) != 2 || got["owner"] != "ops" {
+		t.Fatalf("GetResourceDefaults not match with expect: %+v, error: %v", got, err)
+	}
+
+	// case 1: the missing property is set by defaults, the explicit value win.
+	machine1 := model.NewResource(map[string]string{"hostname": "host1", "owner": "dev"})
+	if err := tree.AppendResource(ns, "machine", machine1); err != nil {
+		t.Fatalf("AppendResource fail: %s", err.Error())
+	}
+	if _, ok := machine1["receiver"]; ok {
+		t.Fatalf("the resource of caller is changed: %+v", machine1)
+	}
+	rl, err := tree.GetResourceList(ns, "machine")
+	if err != nil || len(*rl) != 1 {
+		t.Fatalf("GetResourceList not match with expect: %+v, error: %v", rl, err)
+	}
+	if owner, _ := (*rl)[0].ReadProperty("owner"); owner != "dev" {
+		t.Fatalf("explicit property is overwritten by defaults: %+v", (*rl)[0])
+	}
+	if receiver, _ := (*rl)[0].ReadProperty("receiver"); receiver != "ops-team" {
+		t.Fatalf("default property is not set: %+v", (*rl)[0])
+	}
+
+	// case 2: the registered machine has the defaults.
+	regMap, err := tree.RegisterMachine(model.NewResource(map[string]string{"ip": "10.10.10.1", "hostname": "defaults-machine"}))
+	if err != nil || len(regMap) != 1 {
+		t.Fatalf("RegisterMachine not match with expect, regMap: %+v, error: %v", regMap, err)
+	}
+	machines, err := tree.GetResource(ns, "machine", regMap[ns])
+	if err != nil || len(machines) != 1 {
+		t.Fatalf("get registered machine fail: %+v, error: %v", machines, err)
+	}
+	if owner, _ := machines[0].ReadProperty("owner"); owner != "ops" {
+		t.Fatalf("default property is not set to registered machine: %+v", machines[0])
+	}
+
+	// case 3: the resources are validated with the defaults.
+	if err := tree.SetResourceDefaults("team", map[string]string{"owner": "ops"}); err != nil {
+		t.Fatalf("SetResourceDefaults fail: %s", err.Error())
+	}
+	team := model.NewResource(map[string]string{model.IdKey: "team1"})
+	if errs, err := tree.ValidateResources(ns, "team", team); err != nil || len(errs) != 0 {
+		t.Fatalf("ValidateResources with defaults not match with expect: %+v, error: %v", errs, err)
+	}
+
+	// case 4: remove the defaults.
+	if err := tree.SetResourceDefaults("machine", nil); err != nil {
+		t.Fatalf("remove defaults fail: %s", err.Error())
+	}
+	if got, err := tree.GetResourceDefaults("machine"); err != nil || len(got) != 0 {
+		t.Fatalf("GetResourceDefaults not match with expect: %+v, error: %v", got, err)
+	}
+}
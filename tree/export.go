@@ -0,0 +1,245 @@
+package tree
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/lodastack/registry/common"
+	"github.com/lodastack/registry/model"
+	"github.com/lodastack/registry/tree/cluster"
+	"github.com/lodastack/registry/tree/codec"
+	"github.com/lodastack/registry/tree/node"
+)
+
+// exportVersion is the version of the exported config document.
+const exportVersion = 1
+
+var (
+	// ErrConfigVersion is returned if import the config document of unsupported version.
+	ErrConfigVersion = errors.New("unsupported config version")
+)
+
+// ClusterConfig is the logical config of the tree: the node structure, templates and dashboards.
+// Resource data is not included, which is backuped by Backup.
+type ClusterConfig struct {
+	Version int `json:"version"`
+	// Nodes is ordered by parent first.
+	Nodes []NodeConfig `json:"nodes"`
+}
+
+// NodeConfig is the config of one node.
+type NodeConfig struct {
+	NS         string                        `json:"ns"`
+	Type       int                           `json:"type"`
+	Comment    string                        `json:"comment"`
+	MachineReg string                        `json:"machinereg"`
+	Templates  map[string]model.ResourceList `json:"templates,omitempty"`
+	Dashboards model.DashboardData           `json:"dashboards,omitempty"`
+}
+
+// ExportConfig return the config document of the tree in json.
+func (t *Tree) ExportConfig(ctx context.Context) ([]byte, error) {
+	allNodes, err := t.AllNodes()
+	if err != nil {
+		return nil, err
+	}
+	doc := ClusterConfig{Version: exportVersion}
+	if err := t.exportNode(ctx, allNodes, node.RootNode, &doc); err != nil {
+		return nil, err
+	}
+	return json.Marshal(doc)
+}
+
+func (t *Tree) exportNode(ctx context.Context, n *node.Node, ns string, doc *ClusterConfig) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	nodeConf := NodeConfig{NS: ns, Type: n.Type, Comment: n.Comment, MachineReg: n.MachineReg}
+
+	templates, err := t.templateOfNode(n.ID)
+	if err != nil {
+		t.logger.Errorf("get template of ns %s fail: %s", ns, err.Error())
+		return err
+	}
+	for k, v := range templates {
+		rl := model.ResourceList{}
+		if err := rl.Unmarshal(v); err != nil && err != common.ErrEmptyResource {
+			t.logger.Errorf("unmarshal template %s of ns %s fail: %s", k, ns, err.Error())
+			return err
+		}
+		if nodeConf.Templates == nil {
+			nodeConf.Templates = map[string]model.ResourceList{}
+		}
+		nodeConf.Templates[k] = rl
+	}
+	if nodeConf.Dashboards, err = t.GetDashboard(ns); err != nil {
+		return err
+	}
+	doc.Nodes = append(doc.Nodes, nodeConf)
+
+	for _, child := range n.Children {
+		if err := t.exportNode(ctx, child, child.Name+node.NodeDeli+ns, doc); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validate check the whole document before any change is applied.
+func (doc *ClusterConfig) validate() error {
+	if doc.Version != exportVersion {
+		return ErrConfigVersion
+	}
+	// root node always exist.
+	nodeTypes, seen := map[string]int{node.RootNode: node.NonLeaf}, map[string]bool{}
+	for _, n := range doc.Nodes {
+		if seen[n.NS] {
+			return fmt.Errorf("ns %s is duplicated", n.NS)
+		}
+		seen[n.NS] = true
+		if n.Type != node.Leaf && n.Type != node.NonLeaf {
+			return fmt.Errorf("ns %s has invalid type %d", n.NS, n.Type)
+		}
+		if n.NS != node.RootNode {
+			split := strings.SplitN(n.NS, node.NodeDeli, 2)
+			if len(split) != 2 || split[0] == "" {
+				return fmt.Errorf("ns %s is invalid", n.NS)
+			}
+			if parentType, ok := nodeTypes[split[1]]; !ok || parentType != node.NonLeaf {
+				return fmt.Errorf("parent of ns %s is not nonleaf node before it", n.NS)
+			}
+		} else if n.Type != node.NonLeaf {
+			return fmt.Errorf("root ns %s must be nonleaf", n.NS)
+		}
+		for k := range n.Templates {
+			if !strings.HasPrefix(k, model.TemplatePrefix) {
+				return fmt.Errorf("ns %s has resource %s which is not template", n.NS, k)
+			}
+		}
+		if len(n.Dashboards) != 0 {
+			if n.Type != node.Leaf {
+				return fmt.Errorf("ns %s is nonleaf and could not have dashboards", n.NS)
+			}
+			if errs := validateDashboards(n.Dashboards); len(errs) != 0 {
+				return fmt.Errorf("dashboards of ns %s are invalid: %s", n.NS, errs.Error())
+			}
+		}
+		nodeTypes[n.NS] = n.Type
+	}
+	return nil
+}
+
+// ImportConfig create the node, and set the templates and dashboards by the config document.
+// The whole document is validated, then all the change is written in one batch with the tree of nodes,
+// so the document is applied all or nothing. Existing node is not recreated.
+// Only the buckets of the new nodes are created before the batch, and are removed if the batch fail.
+func (t *Tree) ImportConfig(data []byte) error {
+	var doc ClusterConfig
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return err
+	}
+	if err := doc.validate(); err != nil {
+		t.logger.Errorf("invalid config document: %s", err.Error())
+		return err
+	}
+
+	t.Mu.Lock()
+	defer t.Mu.Unlock()
+	nodes, err := t.copyNodes()
+	if err != nil {
+		return err
+	}
+	for _, n := range doc.Nodes {
+		if exist, err := nodes.GetByNS(n.NS); err == nil && exist.Type != n.Type {
+			return fmt.Errorf("ns %s already exist with type %s", n.NS, node.TypeName(exist.Type))
+		}
+	}
+
+	created := []string{}
+	if err := t.writeConfig(&doc, nodes, &created); err != nil {
+		for _, nodeID := range created {
+			if err := t.removeNodeResourceFromStore(nodeID); err != nil {
+				t.logger.Errorf("remove bucket of node %s fail: %s", nodeID, err.Error())
+			}
+		}
+		return err
+	}
+	t.Nodes = nodes
+	return nil
+}
+
+// writeConfig add the new nodes of the document to nodes, write nodes and all the content in one batch.
+// The ID of the node whose bucket is created is appended to created.
+func (t *Tree) writeConfig(doc *ClusterConfig, nodes *node.Node, created *[]string) error {
+	buffer := cluster.NewBuffer(t.cluster)
+	for _, n := range doc.Nodes {
+		target, err := nodes.GetByNS(n.NS)
+		if err != nil {
+			split := strings.SplitN(n.NS, node.NodeDeli, 2)
+			parent, err := nodes.GetByNS(split[1])
+			if err != nil {
+				t.logger.Errorf("get parent id ns: %s, error: %v", split[1], err)
+				return common.ErrGetParent
+			}
+			if parent.IsLeaf() {
+				t.logger.Error("cannot create node under leaf, leaf node:", split[1])
+				return common.ErrCreateNodeUnderLeaf
+			}
+			newNode := &node.Node{
+				NodeProperty: node.NodeProperty{ID: common.GenUUID(), Name: split[0], Comment: n.Comment, Type: n.Type, MachineReg: n.MachineReg},
+				Children:     []*node.Node{},
+			}
+			if newNode.MachineReg == "" {
+				newNode.MachineReg = node.NotMatchMachine
+			}
+			if err := t.createBucketForNode(newNode.ID); err != nil {
+				t.logger.Errorf("create bucket of ns %s fail when import config: %s", n.NS, err.Error())
+				return err
+			}
+			*created = append(*created, newNode.ID)
+			parent.Children = append(parent.Children, newNode)
+			if err := t.initTemplate(buffer, *newNode, n.Type, split[1], parent.ID); err != nil {
+				return err
+			}
+			target = newNode
+		}
+
+		for k, rl := range n.Templates {
+			resByte, err := rl.Marshal()
+			if err == common.ErrEmptyResource {
+				continue
+			} else if err != nil {
+				return err
+			}
+			if err := cluster.SetByte(buffer, target.ID, k, resByte); err != nil {
+				return err
+			}
+		}
+		if len(n.Dashboards) != 0 {
+			resByte, err := codec.Encode(t.codec, n.Dashboards)
+			if err != nil {
+				t.logger.Errorf("marshal dashboard fail: %s", err.Error())
+				return err
+			}
+			if err := buffer.Update([]byte(target.ID), []byte(dashboardType), resByte); err != nil {
+				return err
+			}
+		}
+	}
+
+	treeByte, err := nodes.MarshalJSON()
+	if err != nil {
+		return err
+	}
+	if err := node.NewNode(buffer).Save(treeByte); err != nil {
+		return err
+	}
+	if err := t.writeWithQuota(t.cluster, buffer.Rows(), t.cluster.Batch); err != nil {
+		t.logger.Errorf("write config document fail: %s", err.Error())
+		return err
+	}
+	return nil
+}
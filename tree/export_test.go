@@ -0,0 +1,111 @@
+package tree
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/lodastack/registry/model"
+	"github.com/lodastack/registry/tree/node"
+	"github.com/lodastack/registry/tree/test_sample"
+)
+
+func TestValidateConfig(t *testing.T) {
+	for i, c := range []struct {
+		doc   ClusterConfig
+		valid bool
+	}{
+		{ClusterConfig{Version: exportVersion, Nodes: []NodeConfig{
+			{NS: node.RootNode, Type: node.NonLeaf},
+			{NS: "a.loda", Type: node.NonLeaf, Templates: map[string]model.ResourceList{"_template_collect": nil}},
+			{NS: "b.a.loda", Type: node.Leaf},
+		}}, true},
+		{ClusterConfig{Version: exportVersion, Nodes: []NodeConfig{{NS: "a.loda", Type: node.Leaf}}}, true},
+		{ClusterConfig{Version: 0, Nodes: []NodeConfig{{NS: "a.loda", Type: node.Leaf}}}, false},
+		{ClusterConfig{Version: exportVersion, Nodes: []NodeConfig{{NS: "b.a.loda", Type: node.Leaf}}}, false},
+		{ClusterConfig{Version: exportVersion, Nodes: []NodeConfig{
+			{NS: "a.loda", Type: node.Leaf}, {NS: "b.a.loda", Type: node.Leaf}}}, false},
+		{ClusterConfig{Version: exportVersion, Nodes: []NodeConfig{
+			{NS: "a.loda", Type: node.Leaf}, {NS: "a.loda", Type: node.Leaf}}}, false},
+		{ClusterConfig{Version: exportVersion, Nodes: []NodeConfig{{NS: "a.loda", Type: node.Root}}}, false},
+		{ClusterConfig{Version: exportVersion, Nodes: []NodeConfig{
+			{NS: "a.loda", Type: node.NonLeaf, Templates: map[string]model.ResourceList{"collect": nil}}}}, false},
+		{ClusterConfig{Version: exportVersion, Nodes: []NodeConfig{
+			{NS: "a.loda", Type: node.NonLeaf, Dashboards: model.DashboardData{{Title: "d0"}}}}}, false},
+		{ClusterConfig{Version: exportVersion, Nodes: []NodeConfig{
+			{NS: "a.loda", Type: node.Leaf, Dashboards: model.DashboardData{{Title: "d0"}, {Title: "d0"}}}}}, false},
+	} {
+		if err := c.doc.validate(); (err == nil) != c.valid {
+			t.Fatalf("validate case %d not match with expect: %v", i, err)
+		}
+	}
+}
+
+func TestExportImportConfig(t *testing.T) {
+	s := test_sample.MustNewStore(t)
+	defer os.RemoveAll(s.Path())
+	if err := s.Open(true); err != nil {
+		t.Fatalf("failed to open single-node store: %s", err.Error())
+	}
+	defer s.Close(true)
+	s.WaitForLeader(10 * time.Second)
+	tree, _ := NewTree(s)
+
+	if _, err := tree.NewNode("exportNonLeaf", "comment", node.RootNode, node.NonLeaf); err != nil {
+		t.Fatalf("create nonleaf fail: %s", err.Error())
+	}
+	if _, err := tree.NewNode("exportLeaf", "comment", "exportNonLeaf.loda", node.Leaf, "^host"); err != nil {
+		t.Fatalf("create leaf fail: %s", err.Error())
+	}
+	if err := tree.AddDashboard("exportLeaf.exportNonLeaf.loda", model.Dashboard{Title: "d0"}); err != nil {
+		t.Fatalf("add dashboard fail: %s", err.Error())
+	}
+	data, err := tree.ExportConfig(context.Background())
+	if err != nil {
+		t.Fatalf("export config fail: %s", err.Error())
+	}
+	var doc ClusterConfig
+	if err := json.Unmarshal(data, &doc); err != nil || doc.validate() != nil {
+		t.Fatalf("exported config invalid: %v", err)
+	}
+
+	s2 := test_sample.MustNewStore(t)
+	defer os.RemoveAll(s2.Path())
+	if err := s2.Open(true); err != nil {
+		t.Fatalf("failed to open single-node store: %s", err.Error())
+	}
+	defer s2.Close(true)
+	s2.WaitForLeader(10 * time.Second)
+	tree2, _ := NewTree(s2)
+
+	if err := tree2.ImportConfig(data); err != nil {
+		t.Fatalf("import config fail: %s", err.Error())
+	}
+	leaf, err := tree2.GetNodeByNS("exportLeaf.exportNonLeaf.loda")
+	if err != nil || leaf.Type != node.Leaf || leaf.MachineReg != "^host" {
+		t.Fatalf("imported node not match with expect: %+v, %v", leaf, err)
+	}
+	dashboards, err := tree2.GetDashboard("exportLeaf.exportNonLeaf.loda")
+	if err != nil || len(dashboards) != 1 || dashboards[0].Title != "d0" {
+		t.Fatalf("imported dashboard not match with expect: %+v, %v", dashboards, err)
+	}
+	// import again is no-op for the existing node.
+	if err := tree2.ImportConfig(data); err != nil {
+		t.Fatalf("import config again fail: %s", err.Error())
+	}
+
+	// nothing is applied if the import fail.
+	invalid, _ := json.Marshal(ClusterConfig{Version: exportVersion, Nodes: []NodeConfig{
+		{NS: "importNonLeaf.loda", Type: node.NonLeaf},
+		{NS: "importLeaf.importNonLeaf.loda", Type: node.Leaf},
+		{NS: "exportNonLeaf.loda", Type: node.Leaf},
+	}})
+	if err := tree2.ImportConfig(invalid); err == nil {
+		t.Fatalf("import config with type mismatch not fail")
+	}
+	if _, err := tree2.GetNodeByNS("importNonLeaf.loda"); err == nil {
+		t.Fatalf("node is created by the failed import")
+	}
+}
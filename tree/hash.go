@@ -0,0 +1,109 @@
+package tree
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"sort"
+
+	"github.com/lodastack/registry/common"
+	"github.com/lodastack/registry/model"
+	"github.com/lodastack/registry/tree/codec"
+	"github.com/lodastack/registry/tree/node"
+)
+
+// NamespaceHash return the sha256 hash of the resources and dashboards of the ns,
+// include its child ns if recursive.
+// The hash not depend on the node ID, the codec of the value or the order of resources,
+// so the same ns on different cluster has the same hash.
+// Return ctx.Err() if ctx is done before the hash is computed.
+func (t *Tree) NamespaceHash(ctx context.Context, ns string, recursive bool) (string, error) {
+	allNodes, err := t.AllNodes()
+	if err != nil {
+		return "", err
+	}
+	n, err := allNodes.GetByNS(ns)
+	if err != nil {
+		t.logger.Errorf("get node of ns %s fail: %s", ns, err.Error())
+		return "", err
+	}
+	h := sha256.New()
+	if err := t.hashNode(ctx, h, n, "", recursive); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// hashNode write the node content to h in stable order, path is the ns relative to the hashed ns.
+func (t *Tree) hashNode(ctx context.Context, h hash.Hash, n *node.Node, path string, recursive bool) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	kv, err := t.cluster.ViewPrefix([]byte(n.ID), []byte{})
+	if err != nil {
+		t.logger.Errorf("view resource of node %s fail: %s", n.ID, err.Error())
+		return err
+	}
+	keys := make([]string, 0, len(kv))
+	for k := range kv {
+		if !isCorruptKey(k) {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		data, err := stableValue(k, kv[k])
+		if err != nil {
+			t.logger.Errorf("decode %s of node %s fail: %s", k, n.ID, err.Error())
+			return err
+		}
+		fmt.Fprintf(h, "%s\n%s\n%d\n", path, k, len(data))
+		h.Write(data)
+	}
+	if !recursive {
+		return nil
+	}
+
+	children := make([]*node.Node, len(n.Children))
+	copy(children, n.Children)
+	sort.Slice(children, func(i, j int) bool { return children[i].Name < children[j].Name })
+	for _, child := range children {
+		childPath := child.Name
+		if path != "" {
+			childPath = child.Name + node.NodeDeli + path
+		}
+		if err := t.hashNode(ctx, h, child, childPath, recursive); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// stableValue decode the value and encode it to json,
+// the resources are sorted by ID and the properties are sorted by key.
+func stableValue(key string, v []byte) ([]byte, error) {
+	if len(v) == 0 {
+		return []byte{}, nil
+	}
+	if key == dashboardType {
+		var dashboards model.DashboardData
+		if err := codec.Decode(v, &dashboards); err != nil {
+			return nil, err
+		}
+		return json.Marshal(dashboards)
+	}
+
+	rl := model.ResourceList{}
+	if err := rl.Unmarshal(v); err != nil && err != common.ErrEmptyResource {
+		return nil, err
+	}
+	sort.SliceStable(rl, func(i, j int) bool {
+		idI, _ := rl[i].ID()
+		idJ, _ := rl[j].ID()
+		return idI < idJ
+	})
+	return json.Marshal(rl)
+}
@@ -0,0 +1,72 @@
+package tree
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/lodastack/registry/model"
+	"github.com/lodastack/registry/tree/node"
+	"github.com/lodastack/registry/tree/test_sample"
+)
+
+func TestNamespaceHash(t *testing.T) {
+	s := test_sample.MustNewStore(t)
+	defer os.RemoveAll(s.Path())
+
+	if err := s.Open(true); err != nil {
+		t.Fatalf("failed to open single-node store: %s", err.Error())
+	}
+	defer s.Close(true)
+	s.WaitForLeader(10 * time.Second)
+	tree, _ := NewTree(s)
+
+	machine1 := model.NewResource(map[string]string{"hostname": "host1"})
+	machine2 := model.NewResource(map[string]string{"hostname": "host2"})
+	machine1.InitID()
+	machine2.InitID()
+	for _, name := range []string{"testHash1", "testHash2"} {
+		if _, err := tree.NewNode(name, "comment", node.RootNode, node.Leaf); err != nil {
+			t.Fatalf("create %s fail: %s", name, err.Error())
+		}
+	}
+	// case 1: same resources in different order has the same hash.
+	if err := tree.SetResource("testHash1."+node.RootNode, "machine", model.ResourceList{machine1, machine2}); err != nil {
+		t.Fatalf("set resource fail: %s", err.Error())
+	}
+	if err := tree.SetResource("testHash2."+node.RootNode, "machine", model.ResourceList{machine2, machine1}); err != nil {
+		t.Fatalf("set resource fail: %s", err.Error())
+	}
+	hash1, err1 := tree.NamespaceHash(context.Background(), "testHash1."+node.RootNode, false)
+	hash2, err2 := tree.NamespaceHash(context.Background(), "testHash2."+node.RootNode, false)
+	if err1 != nil || err2 != nil || hash1 == "" || hash1 != hash2 {
+		t.Fatalf("NamespaceHash not match with expect, hash: %s %s, error: %v %v", hash1, hash2, err1, err2)
+	}
+
+	// case 2: the hash change with the resource.
+	if err := tree.UpdateResource("testHash2."+node.RootNode, "machine", machine1[model.IdKey], map[string]string{"hostname": "host3"}); err != nil {
+		t.Fatalf("update resource fail: %s", err.Error())
+	}
+	if hash2, err2 = tree.NamespaceHash(context.Background(), "testHash2."+node.RootNode, false); err2 != nil || hash1 == hash2 {
+		t.Fatalf("NamespaceHash not match with expect, hash: %s %s, error: %v", hash1, hash2, err2)
+	}
+
+	// case 3: the recursive hash include the child ns.
+	rootHash, err1 := tree.NamespaceHash(context.Background(), node.RootNode, false)
+	recursiveHash, err2 := tree.NamespaceHash(context.Background(), node.RootNode, true)
+	if err1 != nil || err2 != nil || rootHash == recursiveHash {
+		t.Fatalf("NamespaceHash not match with expect, hash: %s %s, error: %v %v", rootHash, recursiveHash, err1, err2)
+	}
+	if err := tree.UpdateResource("testHash2."+node.RootNode, "machine", machine2[model.IdKey], map[string]string{"hostname": "host4"}); err != nil {
+		t.Fatalf("update resource fail: %s", err.Error())
+	}
+	if hash, err := tree.NamespaceHash(context.Background(), node.RootNode, true); err != nil || hash == recursiveHash {
+		t.Fatalf("recursive NamespaceHash not change with child ns, hash: %s, error: %v", hash, err)
+	}
+
+	// case 4: not exist ns.
+	if _, err := tree.NamespaceHash(context.Background(), "notexist."+node.RootNode, false); err == nil {
+		t.Fatal("NamespaceHash of not exist ns not match with expect")
+	}
+}
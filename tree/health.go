@@ -0,0 +1,128 @@
+package tree
+
+import (
+	"strings"
+	"time"
+
+	"github.com/lodastack/registry/model"
+)
+
+// Machine health state classified by the last agent report time.
+const (
+	Healthy = "healthy"
+	Stale   = "stale"
+	Down    = "down"
+
+	defaultStaleAfter = 3 * time.Minute
+	defaultDownAfter  = 10 * time.Minute
+)
+
+// HealthCount is the machine count of each health state.
+type HealthCount struct {
+	Healthy int `json:"healthy"`
+	Stale   int `json:"stale"`
+	Down    int `json:"down"`
+}
+
+func (c *HealthCount) add(state string) {
+	switch state {
+	case Healthy:
+		c.Healthy++
+	case Stale:
+		c.Stale++
+	default:
+		c.Down++
+	}
+}
+
+// HealthSummary is the health rollup of the machines under a ns.
+type HealthSummary struct {
+	HealthCount
+
+	// NS is the health count of every leaf ns under the ns.
+	NS map[string]HealthCount `json:"ns"`
+	// Machines is the hostname-state map.
+	Machines map[string]string `json:"machines"`
+}
+
+// classify return the health state by the duration since last report.
+// The machine never reported is down.
+func classify(last, now time.Time, staleAfter, downAfter time.Duration) string {
+	if last.IsZero() {
+		return Down
+	}
+	switch since := now.Sub(last); {
+	case since >= downAfter:
+		return Down
+	case since >= staleAfter:
+		return Stale
+	}
+	return Healthy
+}
+
+// SetHealthThreshold set the duration after which the machine not reported is stale or down.
+// The default is used if the duration is not positive.
+func (t *Tree) SetHealthThreshold(staleAfter, downAfter time.Duration) {
+	if staleAfter <= 0 {
+		staleAfter = defaultStaleAfter
+	}
+	if downAfter <= 0 {
+		downAfter = defaultDownAfter
+	}
+	t.staleAfter, t.downAfter = staleAfter, downAfter
+}
+
+// lastReportTime return the time this registry node receive the last report of the hostname.
+// Use the time in the persisted report if this node did not receive report from it since started.
+func (t *Tree) lastReportTime(hostname string) time.Time {
+	t.reports.RLock()
+	defer t.reports.RUnlock()
+	if last, ok := t.reports.lastSeen[hostname]; ok {
+		return last
+	}
+	return t.reports.ReportInfo[hostname].UpdateTime
+}
+
+// NodeHealth classify the machines under the ns as healthy/stale/down by their last report time,
+// and roll up the count of the ns and every leaf ns under it.
+// NOTE: the health is local to the registry node answering the request. The last report time is kept
+// in memory of the node which receive the report, it is not replicated to other registry nodes,
+// which only have the report time persisted every PersistReport hours. So the machine reporting to
+// other registry node may be stale or down here.
+func (t *Tree) NodeHealth(ns string) (HealthSummary, error) {
+	summary := HealthSummary{NS: map[string]HealthCount{}, Machines: map[string]string{}}
+	n, err := t.GetNodeByNS(ns)
+	if err != nil {
+		return summary, err
+	}
+	leafNs, err := n.LeafNs()
+	if err != nil {
+		return summary, err
+	}
+
+	now := time.Now()
+	suffix := strings.TrimPrefix(ns, n.Name)
+	for _, leaf := range leafNs {
+		leaf += suffix
+		machines, err := t.GetResourceList(leaf, model.Machine)
+		if err != nil {
+			t.logger.Errorf("get machine of ns %s fail: %s", leaf, err.Error())
+			return summary, err
+		}
+		count := HealthCount{}
+		if machines != nil {
+			for _, machine := range *machines {
+				hostname, _ := machine.ReadProperty(model.HostnameProp)
+				state := classify(t.lastReportTime(hostname), now, t.staleAfter, t.downAfter)
+				count.add(state)
+				summary.Machines[hostname] = state
+			}
+		}
+		summary.NS[leaf] = count
+	}
+	// the machine registered to multiple ns is counted once.
+	for _, state := range summary.Machines {
+		summary.add(state)
+	}
+	return summary, nil
+}
@@ -0,0 +1,33 @@
+package tree
+
+import (
+	"testing"
+	"time"
+)
+
+func TestClassifyHealth(t *testing.T) {
+	now := time.Now()
+	for _, c := range []struct {
+		last   time.Time
+		expect string
+	}{
+		{time.Time{}, Down},
+		{now, Healthy},
+		{now.Add(-time.Minute), Healthy},
+		{now.Add(-3 * time.Minute), Stale},
+		{now.Add(-9 * time.Minute), Stale},
+		{now.Add(-10 * time.Minute), Down},
+	} {
+		if state := classify(c.last, now, defaultStaleAfter, defaultDownAfter); state != c.expect {
+			t.Fatalf("classify %v not match with expect: %s, %s", now.Sub(c.last), state, c.expect)
+		}
+	}
+
+	count := HealthCount{}
+	for _, state := range []string{Healthy, Stale, Down, Down, "unknown"} {
+		count.add(state)
+	}
+	if count.Healthy != 1 || count.Stale != 1 || count.Down != 3 {
+		t.Fatalf("health count not match with expect: %+v", count)
+	}
+}
@@ -2,13 +2,30 @@ package tree
 
 import (
 	"fmt"
+	"sort"
 
+	"github.com/lodastack/registry/common"
 	"github.com/lodastack/registry/model"
+	"github.com/lodastack/registry/tree/node"
 )
 
+// MachineRef is one machine in one ns.
+type MachineRef struct {
+	NS       string `json:"ns"`
+	ID       string `json:"id"`
+	Hostname string `json:"hostname"`
+	IP       string `json:"ip"`
+	Status   string `json:"status"`
+}
+
 // RegisterMachine search and register the machine to the node which match the hostname.
+// The missing properties are set by the defaults of machine.
 func (t *Tree) RegisterMachine(newMachine model.Resource) (map[string]string, error) {
-	return t.machine.RegisterMachine(newMachine)
+	machines, err := t.withDefaults(model.Machine, newMachine)
+	if err != nil {
+		return nil, err
+	}
+	return t.machine.RegisterMachine(machines[0])
 }
 
 // SearchMachine search the hostname in all node.
@@ -16,6 +33,79 @@ func (t *Tree) SearchMachine(hostname string) (map[string][2]string, error) {
 	return t.machine.SearchMachine(hostname)
 }
 
+// MachineNamespaces return the sorted ns list the machine registered under,
+// return empty list if the machine is not on the tree.
+func (t *Tree) MachineNamespaces(hostname string) ([]string, error) {
+	machineRecord, err := t.machine.SearchMachine(hostname)
+	if err != nil {
+		t.logger.Errorf("MachineNamespaces search machine %s fail: %s", hostname, err.Error())
+		return nil, err
+	}
+	nsList := make([]string, 0, len(machineRecord))
+	for ns := range machineRecord {
+		nsList = append(nsList, ns)
+	}
+	sort.Strings(nsList)
+	return nsList, nil
+}
+
+// SearchMachinePaged search the machines which hostname match the query regexp in all node,
+// return the machines in [offset, offset+limit) ordered by hostname and ns, and the total count.
+// Empty query match all machines, statusFilter filter the machine by status if not empty.
+func (t *Tree) SearchMachinePaged(query string, offset, limit int, statusFilter string) ([]MachineRef, int, error) {
+	if offset < 0 || limit <= 0 {
+		return nil, 0, common.ErrInvalidParam
+	}
+	search, err := model.NewSearch(true, model.HostnameProp, query)
+	if err != nil {
+		return nil, 0, err
+	}
+	resMap, err := t.resource.SearchResource(node.RootNode, model.Machine, search)
+	if err != nil {
+		t.logger.Errorf("SearchMachinePaged search machine fail: %s", err.Error())
+		return nil, 0, err
+	}
+	refs := []MachineRef{}
+	for ns, machines := range resMap {
+		for _, m := range *machines {
+			ref := MachineRef{NS: ns}
+			ref.ID, _ = m.ID()
+			ref.Hostname, _ = m.ReadProperty(model.HostnameProp)
+			ref.IP, _ = m.ReadProperty(model.IpProp)
+			ref.Status, _ = m.ReadProperty(model.HostStatusProp)
+			refs = append(refs, ref)
+		}
+	}
+	page, total := machinePage(refs, offset, limit, statusFilter)
+	return page, total, nil
+}
+
+// machinePage filter the machines by status and return the sorted machines in [offset, offset+limit).
+// Return empty page if offset is not less than the count of filtered machines.
+func machinePage(refs []MachineRef, offset, limit int, statusFilter string) ([]MachineRef, int) {
+	filtered := []MachineRef{}
+	for _, ref := range refs {
+		if statusFilter != "" && ref.Status != statusFilter {
+			continue
+		}
+		filtered = append(filtered, ref)
+	}
+	sort.Slice(filtered, func(i, j int) bool {
+		if filtered[i].Hostname != filtered[j].Hostname {
+			return filtered[i].Hostname < filtered[j].Hostname
+		}
+		return filtered[i].NS < filtered[j].NS
+	})
+	if offset >= len(filtered) {
+		return []MachineRef{}, len(filtered)
+	}
+	end := offset + limit
+	if end > len(filtered) {
+		end = len(filtered)
+	}
+	return filtered[offset:end], len(filtered)
+}
+
 // MachineUpdate search the hostname and update the machine resource by updateMap.
 func (t *Tree) MachineUpdate(sn string, oldName string, updateMap map[string]string) error {
 	return t.machine.MachineUpdate(sn, oldName, updateMap)
@@ -7,7 +7,6 @@ package machine
 // Tree could update/remove machine by hostname in all node on the tree.
 
 import (
-	"github.com/lodastack/log"
 	"github.com/lodastack/registry/model"
 	"github.com/lodastack/registry/tree/node"
 	"github.com/lodastack/registry/tree/resource"
@@ -37,10 +36,10 @@ type Inf interface {
 type machine struct {
 	node     node.Inf
 	resource resource.Inf
-	logger   *log.Logger
+	logger   *model.Logger
 }
 
 // NewMachine return the obj which has machine interface.
-func NewMachine(node node.Inf, resource resource.Inf, logger *log.Logger) Inf {
+func NewMachine(node node.Inf, resource resource.Inf, logger *model.Logger) Inf {
 	return &machine{node: node, resource: resource, logger: logger}
 }
@@ -143,6 +143,7 @@ func (m *machine) MatchNs(hostname string) ([]string, error) {
 
 // RegisterMachine registry a machine to the tree.
 // NewMachine mast have property "hostname", it will be used to judge which ns to register.
+// Return NodeTypeError if any ns matched is not leaf.
 func (m *machine) RegisterMachine(newMachine model.Resource) (map[string]string, error) {
 	hostname, ok := newMachine.ReadProperty(model.HostnameProp)
 	if !ok {
@@ -156,6 +157,19 @@ func (m *machine) RegisterMachine(newMachine model.Resource) (map[string]string,
 		return nil, err
 	}
 
+	// check all the ns before append, so the machine is not registered to part of them.
+	for _, ns := range nsList {
+		n, err := m.node.GetNodeByNS(ns)
+		if err != nil {
+			m.logger.Errorf("RegisterMachine fail, get node of ns %s fail: %s", ns, err.Error())
+			return nil, err
+		}
+		if err := n.CheckType(node.Leaf); err != nil {
+			m.logger.Errorf("RegisterMachine to ns %s fail: %s", ns, err.Error())
+			return nil, err
+		}
+	}
+
 	NsIDMap := map[string]string{}
 	for _, ns := range nsList {
 		UUID := newMachine.InitID()
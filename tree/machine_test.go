@@ -143,6 +143,84 @@ func TestSearchMachine(t *testing.T) {
 			t.Fatalf("SearchMachine 127.0.0.1 not match with expect, result: %+v", result)
 		}
 	}
+
+	// case 4: the ns list of machine.
+	if nsList, err := tree.MachineNamespaces("127.0.0.2"); err != nil || len(nsList) != 2 ||
+		nsList[0] != "test1."+node.RootNode || nsList[1] != "test2."+node.RootNode {
+		t.Fatalf("MachineNamespaces 127.0.0.2 not match with expect, result: %v, error: %v", nsList, err)
+	}
+	if nsList, err := tree.MachineNamespaces("127.0.0.0"); err != nil || nsList == nil || len(nsList) != 0 {
+		t.Fatalf("MachineNamespaces 127.0.0.0 not match with expect, result: %v, error: %v", nsList, err)
+	}
+}
+
+func TestSearchMachinePaged(t *testing.T) {
+	s := test_sample.MustNewStore(t)
+	defer os.RemoveAll(s.Path())
+
+	if err := s.Open(true); err != nil {
+		t.Fatalf("failed to open single-node store: %s", err.Error())
+	}
+	defer s.Close(true)
+	s.WaitForLeader(10 * time.Second)
+	tree, err := NewTree(s)
+	if err != nil {
+		t.Fatal("NewTree error")
+	}
+
+	if _, err = tree.NewNode("test1", "comment1", node.RootNode, node.Leaf, "test1"); err != nil {
+		t.Fatalf("create leaf fail: %s", err.Error())
+	}
+	if _, err = tree.NewNode("test2", "comment2", node.RootNode, node.Leaf, "test2"); err != nil {
+		t.Fatalf("create leaf fail: %s", err.Error())
+	}
+	resourceByte1, _ := model.NewResourceList(resMap1)
+	resourceByte2, _ := model.NewResourceList(resMap2)
+	if err = tree.SetResource("test1."+node.RootNode, "machine", *resourceByte1); err != nil {
+		t.Fatalf("set resource fail: %s, not match with expect\n", err.Error())
+	}
+	if err = tree.SetResource("test2."+node.RootNode, "machine", *resourceByte2); err != nil {
+		t.Fatalf("set resource fail: %s, not match with expect\n", err.Error())
+	}
+
+	// case 1: page of all machines, ordered by hostname and ns.
+	result, total, err := tree.SearchMachinePaged("", 1, 2, "")
+	if err != nil || total != 4 || len(result) != 2 {
+		t.Fatalf("SearchMachinePaged not match with expect, total: %d, result: %+v, error: %v", total, result, err)
+	}
+	if result[0].Hostname != "127.0.0.2" || result[0].NS != "test1."+node.RootNode ||
+		result[1].Hostname != "127.0.0.2" || result[1].NS != "test2."+node.RootNode {
+		t.Fatalf("SearchMachinePaged order not match with expect: %+v", result)
+	}
+
+	// case 2: the last page is cut, the page after it is empty.
+	if result, total, err = tree.SearchMachinePaged("127.0.0", 3, 2, ""); err != nil || total != 4 || len(result) != 1 || result[0].Hostname != "127.0.0.3" {
+		t.Fatalf("SearchMachinePaged not match with expect, total: %d, result: %+v, error: %v", total, result, err)
+	}
+	if result, total, err = tree.SearchMachinePaged("127.0.0", 4, 2, ""); err != nil || total != 4 || len(result) != 0 {
+		t.Fatalf("SearchMachinePaged not match with expect, total: %d, result: %+v, error: %v", total, result, err)
+	}
+
+	// case 3: filter by status.
+	if err = tree.UpdateStatusByHostname("127.0.0.2", map[string]string{model.HostStatusProp: model.Dead}); err != nil {
+		t.Fatalf("UpdateStatusByHostname fail: %s", err.Error())
+	}
+	if result, total, err = tree.SearchMachinePaged("", 0, 10, model.Dead); err != nil || total != 2 || len(result) != 2 {
+		t.Fatalf("SearchMachinePaged not match with expect, total: %d, result: %+v, error: %v", total, result, err)
+	}
+	for _, ref := range result {
+		if ref.Hostname != "127.0.0.2" || ref.Status != model.Dead || ref.ID == "" {
+			t.Fatalf("SearchMachinePaged not match with expect: %+v", ref)
+		}
+	}
+
+	// case 4: invalid page.
+	if _, _, err = tree.SearchMachinePaged("", -1, 10, ""); err != common.ErrInvalidParam {
+		t.Fatalf("SearchMachinePaged with invalid offset not match with expect, error: %v", err)
+	}
+	if _, _, err = tree.SearchMachinePaged("", 0, 0, ""); err != common.ErrInvalidParam {
+		t.Fatalf("SearchMachinePaged with invalid limit not match with expect, error: %v", err)
+	}
 }
 
 func TestUpdateStatusByHostname(t *testing.T) {
@@ -387,3 +465,102 @@ func BenchmarkRegisterNewMachine(b *testing.B) {
 		}
 	}
 }
+
+func TestValidateMachines(t *testing.T) {
+	s := test_sample.MustNewStore(t)
+	defer os.RemoveAll(s.Path())
+
+	if err := s.Open(true); err != nil {
+		t.Fatalf("failed to open single-node store: %s", err.Error())
+	}
+	defer s.Close(true)
+	s.WaitForLeader(10 * time.Second)
+	tree, err := NewTree(s)
+	if err != nil {
+		t.Fatal("NewTree error")
+	}
+	if _, err := tree.NewNode("validate", "comment", node.RootNode, node.Leaf, "^validate"); err != nil {
+		t.Fatalf("create leaf fail: %s", err.Error())
+	}
+
+	// case 1: valid machines.
+	if errs, err := tree.ValidateMachines(
+		model.NewResource(map[string]string{"ip": "10.10.10.1", "hostname": "validate-1"}),
+		model.NewResource(map[string]string{"hostname": "validate-2"})); err != nil || len(errs) != 0 {
+		t.Fatalf("ValidateMachines not match with expect: %v, error: %v", errs, err)
+	}
+
+	// case 2: all the problems are returned and nothing is registered.
+	errs, err := tree.ValidateMachines(
+		model.NewResource(map[string]string{"ip": "", "hostname": "validate-1"}),
+		model.NewResource(map[string]string{"ip": "10.10.10.2"}),
+		model.NewResource(map[string]string{"hostname": "validate-1"}))
+	if err != nil {
+		t.Fatalf("ValidateMachines fail: %s", err.Error())
+	}
+	if len(errs) != 3 || errs[0].Index != 0 || errs[1].Index != 1 || errs[2].Index != 2 {
+		t.Fatalf("ValidateMachines not match with expect: %v", errs)
+	}
+	if location, err := tree.SearchMachine("validate-1"); err != nil || len(location) != 0 {
+		t.Fatalf("ValidateMachines should not register machine: %+v, error: %v", location, err)
+	}
+}
+
+func TestDecommissionMachine(t *testing.T) {
+	s := test_sample.MustNewStore(t)
+	defer os.RemoveAll(s.Path())
+
+	if err := s.Open(true); err != nil {
+		t.Fatalf("failed to open single-node store: %s", err.Error())
+	}
+	defer s.Close(true)
+	s.WaitForLeader(10 * time.Second)
+	tree, err := NewTree(s)
+	if err != nil {
+		t.Fatal("NewTree error")
+	}
+	for _, name := range []string{"test1", "test2"} {
+		if _, err := tree.NewNode(name, "comment", node.RootNode, node.Leaf, "test-multi"); err != nil {
+			t.Fatalf("create leaf fail: %s", err.Error())
+		}
+	}
+	machine := model.NewResource(map[string]string{"ip": "10.10.10.1", "hostname": "test-multi-machine"})
+	if regMap, err := tree.RegisterMachine(machine); err != nil || len(regMap) != 2 {
+		t.Fatalf("register machine not match with expect, regMap: %+v, error: %v", regMap, err)
+	}
+
+	if err := tree.DecommissionMachine("test-multi-machine", "admin"); err != nil {
+		t.Fatalf("decommission machine fail: %s", err.Error())
+	}
+	if record, err := tree.SearchMachine("test-multi-machine"); err != nil || len(record) != 0 {
+		t.Fatalf("machine still exist after decommission: %+v, %v", record, err)
+	}
+	if err := tree.DecommissionMachine("test-multi-machine", "admin"); err != common.ErrMachineNotFound {
+		t.Fatalf("decommission not exist machine not match with expect: %v", err)
+	}
+
+	// the archive is kept when the node is renamed.
+	if err := tree.UpdateNode("test1."+node.RootNode, "test3", "comment", "test-multi"); err != nil {
+		t.Fatalf("rename node fail: %s", err.Error())
+	}
+	archived, err := tree.GetArchivedMachines("test3." + node.RootNode)
+	if err != nil || len(archived) != 1 {
+		t.Fatalf("archived machine of ns not match with expect: %+v, %v", archived, err)
+	}
+	if actor, _ := archived[0].ReadProperty(DecommissionActorProp); actor != "admin" {
+		t.Fatalf("decommission actor not match with expect: %+v", archived[0])
+	}
+	if archived, err := tree.GetArchivedMachines(node.RootNode); err != nil || len(archived) != 2 {
+		t.Fatalf("archived machine of root not match with expect: %+v, %v", archived, err)
+	}
+
+	if n, err := tree.PurgeArchivedMachines(time.Now().Add(-time.Hour)); err != nil || n != 0 {
+		t.Fatalf("purge not expired archive not match with expect: %d, %v", n, err)
+	}
+	if n, err := tree.PurgeArchivedMachines(time.Now().Add(time.Hour)); err != nil || n != 2 {
+		t.Fatalf("purge expired archive not match with expect: %d, %v", n, err)
+	}
+	if archived, err := tree.GetArchivedMachines(node.RootNode); err != nil || len(archived) != 0 {
+		t.Fatalf("archived machine after purge not match with expect: %+v, %v", archived, err)
+	}
+}
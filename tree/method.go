@@ -1,6 +1,9 @@
 package tree
 
 import (
+	"context"
+	"time"
+
 	"github.com/lodastack/registry/model"
 	"github.com/lodastack/registry/tree/node"
 )
@@ -18,14 +21,38 @@ type nodeInf interface {
 	// GetNodesById return exact node by nodeid.
 	GetNodeByNS(id string) (*node.Node, error)
 
+	// Walk call visit with each node under the ns and its depth in pre-order.
+	Walk(ns string, visit func(n *node.Node, depth int) error) error
+
+	// NearestAncestorWithProperty return the ns and value of the nearest node set the property, from the ns up to root.
+	NearestAncestorWithProperty(ns, propertyKey string) (string, string, error)
+
 	// Return leaf child node of the ns.
 	LeafChildIDs(ns string) ([]string, error)
+
+	// NodeHealth return the machine health rollup of the ns, seen by this registry node.
+	NodeHealth(ns string) (HealthSummary, error)
+
+	// NamespaceHash return the hash of the resources and dashboards of the ns.
+	NamespaceHash(ctx context.Context, ns string, recursive bool) (string, error)
+
+	// Metrics return the aggregate size of the tree.
+	Metrics() (TreeMetrics, error)
 }
 
 type resourceInf interface {
 	// GetResource return the resourceList by ns/resource type/resource ID.
 	GetResource(ns, resType string, resID ...string) ([]model.Resource, error)
 
+	// GetResourceProjected return the resources with only the fields.
+	GetResourceProjected(ns, resType string, fields []string, resID ...string) ([]map[string]string, error)
+
+	// WalkResource call fn with the resources of ns one by one.
+	WalkResource(ns, resType string, fn func(model.Resource) error) error
+
+	// GetResourceByIndex return the resources whose property is the value by the secondary index.
+	GetResourceByIndex(ns, resType, property, value string) ([]model.Resource, error)
+
 	// Get resource by NodeName and resour type
 	GetResourceList(NodeName string, ResourceType string) (*model.ResourceList, error)
 
@@ -38,26 +65,90 @@ type resourceInf interface {
 	// Update Resource By ns and ResourceID.
 	UpdateResource(ns, resType, resID string, updateMap map[string]string) error
 
+	// TouchResource set the touch time of the resource to now, the resource itself is not written.
+	TouchResource(ns, resType, resID string) error
+
+	// TouchTime return the time the resource is touched last time, zero time if it is never touched.
+	TouchTime(ns, resType, resID string) (time.Time, error)
+
 	// Append resource to ns.
 	AppendResource(ns, resType string, appendRes ...model.Resource) error
 
+	// ValidateResources check all the resources to append to ns, return the problems found.
+	ValidateResources(ns, resType string, rs ...model.Resource) (ValidationErrors, error)
+
 	// CopyResource copy resource from fromNs to toNs.
 	CopyResource(fromNs, toNs, resType string, resID ...string) error
 
 	// Remove resource from ns.
 	RemoveResource(ns, resType string, resId ...string) error
 
+	// SoftRemoveResource remove resources from ns and keep them as tombstone.
+	SoftRemoveResource(ns, resType string, resIDs ...string) error
+
+	// RestoreResource restore the soft removed resources to ns.
+	RestoreResource(ns, resType string, resIDs ...string) error
+
+	// GetDeletedResources return the soft removed resources of ns.
+	GetDeletedResources(ns, resType string) (model.ResourceList, error)
+
+	// RunPurge purge the expired tombstone and archive periodically when isLeader return true, return the stop func.
+	RunPurge(isLeader func() bool) (stop func())
+
+	// GetResourceIncludeDeleted return the resources of ns include the soft removed ones.
+	GetResourceIncludeDeleted(ns, resType string, resID ...string) ([]model.Resource, error)
+
+	// RekeyResource change the resource ID from oldID to newID.
+	RekeyResource(ns, resType, oldID, newID string) error
+
 	// Remove resource from one ns to another.
 	MoveResource(oldNs, newNs, resType string, resourceID ...string) error
+
+	// MoveResourceWithRefs move resource and keep its ID, return the dashboard targets may reference it.
+	MoveResourceWithRefs(oldNs, newNs, resType string, resourceID ...string) ([]DanglingRef, error)
+
+	// ResourceTypes return the resource types stored in the ns.
+	ResourceTypes(ns string) ([]string, error)
+
+	// CountResources return the resource type - count map of the ns and all its child ns.
+	CountResources(ctx context.Context, ns string) (map[string]int, error)
+
+	// WalkSubtree call visit with each node under the ns and its resources.
+	// It is not a snapshot of the subtree, the report need a consistent view should not use it.
+	WalkSubtree(ctx context.Context, ns string, visit func(n *node.Node, resources map[string][]model.Resource) error) error
+
+	// SetResourceDefaults set the default properties of the resource type.
+	SetResourceDefaults(resType string, defaults map[string]string) error
+
+	// GetResourceDefaults return the default properties of the resource type.
+	GetResourceDefaults(resType string) (map[string]string, error)
+
+	// SetQuota set the resource count quota of the ns and its child ns.
+	SetQuota(ns string, quota int) error
+
+	// GetQuotaUsage return the quota and the resource count of the ns.
+	GetQuotaUsage(ctx context.Context, ns string) (QuotaUsage, error)
+
+	// ListQuotaUsage return the quota and the resource count of all ns which has quota.
+	ListQuotaUsage(ctx context.Context) ([]QuotaUsage, error)
 }
 
 type machineInf interface {
 	// Search Machine on tree.
 	SearchMachine(hostname string) (map[string][2]string, error)
 
+	// MachineNamespaces return the ns list the machine registered under.
+	MachineNamespaces(hostname string) ([]string, error)
+
+	// SearchMachinePaged search machine by hostname regexp and status, return one ordered page and the total count.
+	SearchMachinePaged(query string, offset, limit int, statusFilter string) ([]MachineRef, int, error)
+
 	// Regist machine on the tree.
 	RegisterMachine(newMachine model.Resource) (map[string]string, error)
 
+	// ValidateMachines check all the machines to register, return the problems found.
+	ValidateMachines(machines ...model.Resource) (ValidationErrors, error)
+
 	// Update hostname property of machine resource.
 	MachineUpdate(sn string, oldName string, updateMap map[string]string) error
 
@@ -66,6 +157,12 @@ type machineInf interface {
 
 	// UpdateStatusByHostname search and remove machine.
 	RemoveStatusByHostname(hostname string) error
+
+	// DecommissionMachine remove the machine from all ns and archive it.
+	DecommissionMachine(hostname, actor string) error
+
+	// GetArchivedMachines return the machines decommissioned from the ns and its child ns.
+	GetArchivedMachines(ns string) (model.ResourceList, error)
 }
 
 // TreeMethod is the interface tree must implement.
@@ -78,9 +175,33 @@ type TreeMethod interface {
 	// NewNode create node.
 	NewNode(name, comment, parentNs string, nodeType int, property ...string) (string, error)
 
+	// NewNodeIfNotExist create node if the node not exist, return the node ID and whether it is created.
+	NewNodeIfNotExist(name, comment, parentNs string, nodeType int, property ...string) (string, bool, error)
+
+	// EnsureNode return the ID of the node of ns or create it, and the missing parents if createParents.
+	EnsureNode(ns, comment string, nodeType int, createParents bool) (string, bool, error)
+
+	// NewNodeWithContent create node with its initial resources and dashboards.
+	NewNodeWithContent(name, comment, parentNs string, nodeType int, initial NodeContent) (string, error)
+
 	// Update the node property.
 	UpdateNode(ns string, name, comment, machineReg string) error
 
 	// RemoveNode remove the node with delID from parentNs.
 	RemoveNode(ns string) error
+
+	// PreviewRepair report whether the value of ns/resType is parseable.
+	PreviewRepair(ns, resType string) (RepairReport, error)
+
+	// ExportConfig return the node structure, templates and dashboards as a json document.
+	ExportConfig(ctx context.Context) ([]byte, error)
+
+	// ImportConfig apply the document return by ExportConfig.
+	ImportConfig(data []byte) error
+
+	// Tx apply the changes made by fn to resources and dashboards in one batch.
+	Tx(fn func(TreeTx) error) error
+
+	// RepairValueWithReport quarantine and reset the value of ns/resType if it is unparseable.
+	RepairValueWithReport(ns, resType string) (RepairReport, error)
 }
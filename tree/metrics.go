@@ -0,0 +1,76 @@
+package tree
+
+import (
+	"strings"
+
+	"github.com/lodastack/registry/common"
+	"github.com/lodastack/registry/model"
+	"github.com/lodastack/registry/tree/codec"
+	"github.com/lodastack/registry/tree/node"
+)
+
+// metricsMaxDepth is the max depth Metrics walk into, the deeper nodes are not counted.
+const metricsMaxDepth = 64
+
+// TreeMetrics is the aggregate size of the tree.
+type TreeMetrics struct {
+	Nodes      int            `json:"nodes"`
+	MaxDepth   int            `json:"maxdepth"`
+	Resources  map[string]int `json:"resources"`
+	Dashboards int            `json:"dashboards"`
+	Panels     int            `json:"panels"`
+	// Truncated is true if some node deeper than metricsMaxDepth is not counted.
+	Truncated bool `json:"truncated"`
+}
+
+// Metrics return the node count, max depth, resource count by type and dashboard/panel count of the tree.
+// The metrics is computed in one walk of the tree, which read and decode all resources of every node,
+// so it cost as much as reading the whole tree. Do not call it frequently on very large tree.
+// The template is not counted.
+func (t *Tree) Metrics() (TreeMetrics, error) {
+	metrics := TreeMetrics{Resources: map[string]int{}}
+	err := t.Walk(node.RootNode, func(n *node.Node, depth int) error {
+		if depth > metricsMaxDepth {
+			metrics.Truncated = true
+			return ErrSkipSubtree
+		}
+		metrics.Nodes++
+		if depth > metrics.MaxDepth {
+			metrics.MaxDepth = depth
+		}
+
+		kv, err := t.cluster.ViewPrefix([]byte(n.ID), []byte{})
+		if err != nil {
+			t.logger.Errorf("view resource of node %s fail: %s", n.ID, err.Error())
+			return err
+		}
+		for k, v := range kv {
+			if isCorruptKey(k) || strings.HasPrefix(k, model.TemplatePrefix) || len(v) == 0 {
+				continue
+			}
+			if k == dashboardType {
+				var dashboards model.DashboardData
+				if err := codec.Decode(v, &dashboards); err != nil {
+					t.logger.Errorf("decode dashboard of node %s fail: %s", n.ID, err.Error())
+					return err
+				}
+				metrics.Dashboards += len(dashboards)
+				for _, d := range dashboards {
+					metrics.Panels += len(d.Panels)
+				}
+				continue
+			}
+			rl := model.ResourceList{}
+			if err := rl.Unmarshal(v); err != nil && err != common.ErrEmptyResource {
+				t.logger.Errorf("unmarshal resource %s of node %s fail: %s", k, n.ID, err.Error())
+				return err
+			}
+			metrics.Resources[k] += len(rl)
+		}
+		return nil
+	})
+	if err != nil {
+		return TreeMetrics{}, err
+	}
+	return metrics, nil
+}
@@ -1,10 +1,17 @@
 package tree
 
 import (
+	"errors"
+
 	"github.com/lodastack/registry/common"
 	"github.com/lodastack/registry/tree/node"
 )
 
+var (
+	// ErrSkipSubtree is returned by the visit of Walk to skip the child nodes of the node.
+	ErrSkipSubtree = errors.New("skip this subtree")
+)
+
 // AllNodes return the root node.
 func (t *Tree) AllNodes() (n *node.Node, err error) {
 	if n, err = t.node.AllNodes(); err != nil {
@@ -48,3 +55,77 @@ func (t *Tree) LeafChildIDs(ns string) (l []string, err error) {
 	}
 	return
 }
+
+// nodeProperty return the value of the node property named by its json key, and whether it is set.
+func nodeProperty(n *node.Node, key string) (string, bool, error) {
+	switch key {
+	case "comment":
+		return n.Comment, n.Comment != "", nil
+	case "machinereg":
+		return n.MachineReg, n.MachineReg != "" && n.MachineReg != node.NotMatchMachine, nil
+	default:
+		return "", false, common.ErrInvalidParam
+	}
+}
+
+// NearestAncestorWithProperty walk from the ns up to the root node,
+// return the ns and value of the first node which set the property.
+// Return empty ns and value if no node set the property.
+func (t *Tree) NearestAncestorWithProperty(ns, propertyKey string) (string, string, error) {
+	allNodes, err := t.AllNodes()
+	if err != nil {
+		return "", "", err
+	}
+	if !allNodes.Exist(ns) {
+		return "", "", common.ErrNodeNotFound
+	}
+	for cur := ns; ; {
+		n, err := allNodes.GetByNS(cur)
+		if err != nil {
+			t.logger.Errorf("get node of ns %s fail: %s", cur, err.Error())
+			return "", "", err
+		}
+		value, set, err := nodeProperty(n, propertyKey)
+		if err != nil {
+			return "", "", err
+		}
+		if set {
+			return cur, value, nil
+		}
+		if cur, err = getParentNS(cur); err != nil {
+			// reach the root node.
+			return "", "", nil
+		}
+	}
+}
+
+// Walk call visit with the node and its depth for the ns and all its child node in pre-order,
+// the depth of the ns is 0 and the child nodes are visited in their order.
+// The nodes are read once before walking, so the change of the tree during walking is not seen.
+// Walking stop if visit return error, the child nodes are skipped if visit return ErrSkipSubtree.
+func (t *Tree) Walk(ns string, visit func(n *node.Node, depth int) error) error {
+	allNodes, err := t.AllNodes()
+	if err != nil {
+		return err
+	}
+	n, err := allNodes.GetByNS(ns)
+	if err != nil {
+		t.logger.Errorf("get node of ns %s fail: %s", ns, err.Error())
+		return err
+	}
+	return walkPreOrder(n, 0, visit)
+}
+
+func walkPreOrder(n *node.Node, depth int, visit func(n *node.Node, depth int) error) error {
+	if err := visit(n, depth); err == ErrSkipSubtree {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	for _, child := range n.Children {
+		if err := walkPreOrder(child, depth+1, visit); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -1,6 +1,7 @@
 package node
 
 import (
+	"fmt"
 	"strings"
 
 	"github.com/lodastack/registry/common"
@@ -129,6 +130,53 @@ func (n *Node) RemoveChildNode(childID string) error {
 	return common.ErrNodeNotFound
 }
 
+// TypeName return the readable name of node type.
+func TypeName(nodeType int) string {
+	switch nodeType {
+	case Leaf:
+		return "leaf"
+	case NonLeaf:
+		return "nonleaf"
+	case Root:
+		return "root"
+	}
+	return fmt.Sprintf("unknown(%d)", nodeType)
+}
+
+// NodeTypeError is returned if the operation is not allowed on the type of node.
+type NodeTypeError struct {
+	Name   string
+	Type   int
+	Expect int
+}
+
+func (e *NodeTypeError) Error() string {
+	return fmt.Sprintf("%s: node %s is %s node, expect %s node",
+		common.ErrWrongNodeType.Error(), e.Name, TypeName(e.Type), TypeName(e.Expect))
+}
+
+// Unwrap return common.ErrWrongNodeType.
+func (e *NodeTypeError) Unwrap() error {
+	return common.ErrWrongNodeType
+}
+
+// CheckType return NodeTypeError if the node is not the expect type.
+func (n *Node) CheckType(expect int) error {
+	if n.Type != expect {
+		return &NodeTypeError{Name: n.Name, Type: n.Type, Expect: expect}
+	}
+	return nil
+}
+
+// CheckResource return NodeTypeError if the node could not be set the resource.
+// See AllowResource.
+func (n *Node) CheckResource(resType string) error {
+	if !n.AllowResource(resType) {
+		return &NodeTypeError{Name: n.Name, Type: n.Type, Expect: Leaf}
+	}
+	return nil
+}
+
 // AllowResource checks if the node could be set a resource.
 // Leaf node could set/get resource;
 // NonLeaf node could be only set/get template resource.
@@ -7,6 +7,7 @@ import (
 	"unsafe"
 
 	"github.com/lodastack/registry/common"
+	"github.com/lodastack/registry/model"
 	"github.com/lodastack/registry/tree/test_sample"
 )
 
@@ -170,6 +171,30 @@ func TestUpdateNode(t *testing.T) {
 	}
 }
 
+func TestCheckNodeType(t *testing.T) {
+	leaf := Node{NodeProperty{ID: "1", Name: "leaf", Type: Leaf}, nil}
+	nonLeaf := Node{NodeProperty{ID: "2", Name: "nonleaf", Type: NonLeaf}, nil}
+
+	if err := leaf.CheckType(Leaf); err != nil {
+		t.Fatalf("check leaf type fail: %s", err.Error())
+	}
+	if err := leaf.CheckResource("machine"); err != nil {
+		t.Fatalf("check leaf resource fail: %s", err.Error())
+	}
+	if err := nonLeaf.CheckResource(model.TemplatePrefix + "machine"); err != nil {
+		t.Fatalf("check nonleaf template fail: %s", err.Error())
+	}
+
+	err := nonLeaf.CheckResource("machine")
+	typeErr, ok := err.(*NodeTypeError)
+	if !ok || typeErr.Expect != Leaf || typeErr.Type != NonLeaf || typeErr.Unwrap() != common.ErrWrongNodeType {
+		t.Fatalf("check nonleaf resource not match with expect: %v", err)
+	}
+	if err := leaf.CheckType(NonLeaf); err == nil || err.Error() != "wrong node type: node leaf is leaf node, expect nonleaf node" {
+		t.Fatalf("check leaf type not match with expect: %v", err)
+	}
+}
+
 func TestRemoveChildNode(t *testing.T) {
 	nodeTest := Node{
 		NodeProperty{ID: RootNode, Name: RootNode, Type: NonLeaf, MachineReg: "*"},
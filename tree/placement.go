@@ -0,0 +1,68 @@
+package tree
+
+import (
+	"hash/crc32"
+	"sort"
+	"strconv"
+
+	"github.com/lodastack/registry/model"
+)
+
+// defaultReplicas is the virtual node number of each worker if replicas is not positive.
+const defaultReplicas = 100
+
+// Ring is the consistent hash ring of workers.
+// Only the keys of the removed worker, or the keys the added worker takes, are moved
+// when the workers change.
+type Ring struct {
+	hashes  []uint32
+	workers map[uint32]string
+}
+
+// NewRing return the ring of the workers, each worker has replicas virtual nodes on the ring.
+func NewRing(workers []string, replicas int) *Ring {
+	if replicas <= 0 {
+		replicas = defaultReplicas
+	}
+	r := &Ring{workers: make(map[uint32]string, len(workers)*replicas)}
+	for _, worker := range workers {
+		for i := 0; i < replicas; i++ {
+			h := crc32.ChecksumIEEE([]byte(strconv.Itoa(i) + worker))
+			// keep the first worker if the hash collide.
+			if _, ok := r.workers[h]; ok {
+				continue
+			}
+			r.workers[h] = worker
+			r.hashes = append(r.hashes, h)
+		}
+	}
+	sort.Slice(r.hashes, func(i, j int) bool { return r.hashes[i] < r.hashes[j] })
+	return r
+}
+
+// Get return the worker the key is placed on, return empty string if the ring has no worker.
+func (r *Ring) Get(key string) string {
+	if len(r.hashes) == 0 {
+		return ""
+	}
+	h := crc32.ChecksumIEEE([]byte(key))
+	i := sort.Search(len(r.hashes), func(i int) bool { return r.hashes[i] >= h })
+	if i == len(r.hashes) {
+		i = 0
+	}
+	return r.workers[r.hashes[i]]
+}
+
+// ConsistentPlacement place the resources to the workers by consistent hash of resource ID,
+// return the worker - resource ID list map.
+func ConsistentPlacement(resources []model.Resource, workers []string, replicas int) map[string][]string {
+	placement := make(map[string][]string, len(workers))
+	r := NewRing(workers, replicas)
+	for _, res := range resources {
+		id, _ := res.ID()
+		if worker := r.Get(id); worker != "" {
+			placement[worker] = append(placement[worker], id)
+		}
+	}
+	return placement
+}
@@ -0,0 +1,40 @@
+package tree
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/lodastack/registry/model"
+)
+
+func TestConsistentPlacement(t *testing.T) {
+	resources := make([]model.Resource, 1000)
+	for i := range resources {
+		resources[i] = model.Resource{model.IdKey: "resource-" + strconv.Itoa(i)}
+	}
+	workers := []string{"worker1", "worker2", "worker3"}
+	placement := ConsistentPlacement(resources, workers, 0)
+	total := 0
+	for _, worker := range workers {
+		if len(placement[worker]) == 0 {
+			t.Fatalf("worker %s has no resource: %v", worker, placement)
+		}
+		total += len(placement[worker])
+	}
+	if total != len(resources) {
+		t.Fatalf("placed resource number not match with expect: %d", total)
+	}
+
+	// only the resource placed on the new worker is moved.
+	old, added := NewRing(workers, 0), NewRing(append(workers, "worker4"), 0)
+	for _, res := range resources {
+		id, _ := res.ID()
+		if w := added.Get(id); w != old.Get(id) && w != "worker4" {
+			t.Fatalf("resource %s moved from %s to %s, not match with expect", id, old.Get(id), w)
+		}
+	}
+
+	if NewRing(nil, 0).Get("resource-0") != "" {
+		t.Fatalf("ring without worker return worker, not match with expect")
+	}
+}
@@ -0,0 +1,123 @@
+package tree
+
+import (
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/lodastack/registry/common"
+	"github.com/lodastack/registry/config"
+	"github.com/lodastack/registry/model"
+	"github.com/lodastack/registry/tree/cluster"
+
+	sm "github.com/lodastack/store/model"
+)
+
+// purgeInterval is the interval RunPurge check the expired tombstone and archive.
+var purgeInterval = time.Hour
+
+// RunPurge purge the expired tombstone and archived machine every purgeInterval by the retention config,
+// only when isLeader return true, so the purge is run by one registry node of the cluster.
+// Call the returned func to stop it.
+func (t *Tree) RunPurge(isLeader func() bool) (stop func()) {
+	ticker := time.NewTicker(purgeInterval)
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-ticker.C:
+				if isLeader() {
+					t.purgeByRetention(time.Now())
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			ticker.Stop()
+			close(done)
+		})
+	}
+}
+
+// purgeByRetention purge the tombstone and archived machine older than their retention days before now.
+func (t *Tree) purgeByRetention(now time.Time) {
+	if days := config.C.CommonConf.TombstoneRetention; days > 0 {
+		if _, err := t.PurgeTombstones(now.Add(-time.Duration(days) * 24 * time.Hour)); err != nil {
+			t.logger.Error("PurgeTombstones fail:", err.Error())
+		}
+	}
+	if days := config.C.CommonConf.ArchiveRetention; days > 0 {
+		if _, err := t.PurgeArchivedMachines(now.Add(-time.Duration(days) * 24 * time.Hour)); err != nil {
+			t.logger.Error("PurgeArchivedMachines fail:", err.Error())
+		}
+	}
+}
+
+// readResourcesByPrefix return the resources saved in the bucket by the keys have the prefix.
+func readResourcesByPrefix(c cluster.Inf, bucket, prefix string) (model.ResourceList, error) {
+	kv, err := c.ViewPrefix([]byte(bucket), []byte(prefix))
+	if err != nil {
+		return nil, err
+	}
+	result := model.ResourceList{}
+	for _, v := range kv {
+		rl := model.ResourceList{}
+		if err := rl.Unmarshal(v); err != nil && err != common.ErrEmptyResource {
+			return nil, err
+		}
+		result.AppendResources(rl)
+	}
+	return result, nil
+}
+
+// purgeExpired remove the resources saved in the bucket whose unix time property timeProp is before the time,
+// return the number of resource purged.
+// Only the key whose resources are all expired is written, with empty value.
+// NOTE: the key is read and removed without store CAS, a key written again between them,
+// e.g. the resource with the same ID soft removed again on other registry node, is removed too.
+// Run it by RunPurge on the leader only.
+func (t *Tree) purgeExpired(bucket, timeProp string, before time.Time) (int, error) {
+	t.retainMu.Lock()
+	defer t.retainMu.Unlock()
+	kv, err := t.cluster.ViewPrefix([]byte(bucket), []byte{})
+	if err != nil {
+		t.logger.Errorf("view %s fail: %s", bucket, err.Error())
+		return 0, err
+	}
+	purged, rows := 0, []sm.Row{}
+	for k, v := range kv {
+		saved := model.ResourceList{}
+		if err := saved.Unmarshal(v); err != nil && err != common.ErrEmptyResource {
+			t.logger.Errorf("unmarshal %s of %s fail: %s", bucket, k, err.Error())
+			continue
+		}
+		if len(saved) == 0 || !allExpired(saved, timeProp, before) {
+			continue
+		}
+		purged += len(saved)
+		rows = append(rows, sm.Row{Bucket: []byte(bucket), Key: []byte(k), Value: []byte{}})
+	}
+	if len(rows) == 0 {
+		return 0, nil
+	}
+	if err := t.cluster.Batch(rows); err != nil {
+		t.logger.Errorf("purge %s fail: %s", bucket, err.Error())
+		return 0, err
+	}
+	return purged, nil
+}
+
+// allExpired return whether the unix time property timeProp of all the resources is before the time.
+func allExpired(rl model.ResourceList, timeProp string, before time.Time) bool {
+	for _, r := range rl {
+		savedTime, _ := r.ReadProperty(timeProp)
+		if sec, err := strconv.ParseInt(savedTime, 10, 64); err != nil || sec >= before.Unix() {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,283 @@
+package tree
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/lodastack/registry/common"
+	"github.com/lodastack/registry/tree/cluster"
+	"github.com/lodastack/registry/tree/node"
+	"github.com/lodastack/registry/tree/resource"
+
+	sm "github.com/lodastack/store/model"
+)
+
+// quotaBucket save the resource count quota, the key is the ns and the value is the quota.
+// The quota limit the count of resources in the ns and all its child ns.
+// The count of resources in use is saved under usagePrefix+ns, and is updated with the write of resources.
+const (
+	quotaBucket = "quota"
+	usagePrefix = "usage/"
+)
+
+// QuotaUsage is the resource count quota of the ns and the count of resource in use.
+// Quota is 0 if the ns has no quota.
+type QuotaUsage struct {
+	NS    string `json:"ns"`
+	Quota int    `json:"quota"`
+	Usage int    `json:"usage"`
+}
+
+func (t *Tree) initQuotaBucket() error {
+	if err := t.cluster.CreateBucketIfNotExist([]byte(quotaBucket)); err != nil {
+		t.logger.Errorf("tree init %s CreateBucketIfNotExist fail: %s", quotaBucket, err.Error())
+		return err
+	}
+	return nil
+}
+
+func usageKey(ns string) []byte {
+	return []byte(usagePrefix + ns)
+}
+
+// readQuotas return the ns - quota map.
+func (t *Tree) readQuotas() (map[string]int, error) {
+	kv, err := t.cluster.ViewPrefix([]byte(quotaBucket), []byte{})
+	if err != nil {
+		t.logger.Errorf("view quota fail: %s", err.Error())
+		return nil, err
+	}
+	quotas := make(map[string]int, len(kv))
+	for ns, v := range kv {
+		if len(v) == 0 || strings.HasPrefix(ns, usagePrefix) {
+			continue
+		}
+		quota, err := strconv.Atoi(string(v))
+		if err != nil {
+			t.logger.Errorf("invalid quota of ns %s: %s", ns, string(v))
+			continue
+		}
+		quotas[ns] = quota
+	}
+	return quotas, nil
+}
+
+// SetQuota set the resource count quota of the ns and its child ns, remove the quota if quota is 0.
+// The usage of the ns is counted when the quota is set, and is updated by the write of resources since then.
+func (t *Tree) SetQuota(ns string, quota int) error {
+	if quota < 0 {
+		return common.ErrInvalidParam
+	}
+	allNodes, err := t.AllNodes()
+	if err != nil {
+		return err
+	}
+	n, err := allNodes.GetByNS(ns)
+	if err != nil {
+		t.logger.Errorf("get node of ns %s fail: %s", ns, err.Error())
+		return common.ErrNodeNotFound
+	}
+
+	t.quotaMu.Lock()
+	defer t.quotaMu.Unlock()
+	rows := []sm.Row{
+		{Bucket: []byte(quotaBucket), Key: []byte(ns), Value: []byte{}},
+		{Bucket: []byte(quotaBucket), Key: usageKey(ns), Value: []byte{}},
+	}
+	if quota != 0 {
+		usage, err := t.subtreeUsage(context.Background(), t.cluster, n)
+		if err != nil {
+			return err
+		}
+		rows[0].Value, rows[1].Value = []byte(strconv.Itoa(quota)), []byte(strconv.Itoa(usage))
+	}
+	if err := t.cluster.Batch(rows); err != nil {
+		t.logger.Errorf("set quota of ns %s fail: %s", ns, err.Error())
+		return err
+	}
+	return nil
+}
+
+// GetQuotaUsage return the quota and the resource count of the ns.
+func (t *Tree) GetQuotaUsage(ctx context.Context, ns string) (QuotaUsage, error) {
+	quotas, err := t.readQuotas()
+	if err != nil {
+		return QuotaUsage{}, err
+	}
+	allNodes, err := t.AllNodes()
+	if err != nil {
+		return QuotaUsage{}, err
+	}
+	n, err := allNodes.GetByNS(ns)
+	if err != nil {
+		t.logger.Errorf("get node of ns %s fail: %s", ns, err.Error())
+		return QuotaUsage{}, err
+	}
+	usage, err := t.quotaUsage(ctx, t.cluster, n, ns)
+	if err != nil {
+		return QuotaUsage{}, err
+	}
+	return QuotaUsage{NS: ns, Quota: quotas[ns], Usage: usage}, nil
+}
+
+// ListQuotaUsage return the quota and the resource count of all ns which has quota, ordered by ns.
+func (t *Tree) ListQuotaUsage(ctx context.Context) ([]QuotaUsage, error) {
+	quotas, err := t.readQuotas()
+	if err != nil {
+		return nil, err
+	}
+	allNodes, err := t.AllNodes()
+	if err != nil {
+		return nil, err
+	}
+	result := make([]QuotaUsage, 0, len(quotas))
+	for ns, quota := range quotas {
+		n, err := allNodes.GetByNS(ns)
+		if err != nil {
+			continue
+		}
+		usage, err := t.quotaUsage(ctx, t.cluster, n, ns)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, QuotaUsage{NS: ns, Quota: quota, Usage: usage})
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].NS < result[j].NS })
+	return result, nil
+}
+
+// quotaUsage return the usage of the ns saved in c, count the subtree if the usage is not saved,
+// e.g. it is reset after the node is removed.
+func (t *Tree) quotaUsage(ctx context.Context, c cluster.Inf, n *node.Node, ns string) (int, error) {
+	v, err := c.View([]byte(quotaBucket), usageKey(ns))
+	if err != nil {
+		return 0, err
+	}
+	if len(v) != 0 {
+		if usage, err := strconv.Atoi(string(v)); err == nil {
+			return usage, nil
+		}
+		t.logger.Errorf("invalid usage of ns %s: %s, count it again", ns, string(v))
+	}
+	return t.subtreeUsage(ctx, c, n)
+}
+
+// subtreeUsage return the count of resources in the ns and its child ns read from c.
+// The resources are counted without unmarshal and the template is not counted.
+func (t *Tree) subtreeUsage(ctx context.Context, c cluster.Inf, n *node.Node) (int, error) {
+	usage := 0
+	err := walkPreOrder(n, 0, func(child *node.Node, _ int) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		counts := map[string]int{}
+		if err := t.countNode(c, child.ID, counts); err != nil {
+			return err
+		}
+		for _, count := range counts {
+			usage += count
+		}
+		return nil
+	})
+	return usage, err
+}
+
+// writeWithQuota is the resource.QuotaFunc of the tree, it is also used to commit the buffer of tx.
+// Return ErrQuotaExceeded if the rows make the resource count exceed the quota of any ns,
+// otherwise write the rows with the new usage of the ns by write in one batch.
+// The check and the write is serialized by quotaMu, so the usage is not changed between them in this process.
+// NOTE: the usage is saved as a value not a counter in the store, the writes from other registry node
+// at the same time may make it drift, it is counted again when the quota is set.
+func (t *Tree) writeWithQuota(c cluster.Inf, rows []sm.Row, write func([]sm.Row) error) error {
+	t.quotaMu.Lock()
+	defer t.quotaMu.Unlock()
+	usageRows, err := t.quotaRows(c, rows)
+	if err != nil {
+		return err
+	}
+	return write(append(rows, usageRows...))
+}
+
+// quotaRows return the rows of the new usage of the ns whose subtree is changed by rows,
+// the resource moved inside the subtree do not change its usage.
+func (t *Tree) quotaRows(c cluster.Inf, rows []sm.Row) ([]sm.Row, error) {
+	quotas, err := t.readQuotas()
+	if err != nil || len(quotas) == 0 {
+		return nil, err
+	}
+	// read the nodes with the rows applied, so the node created in the same batch is counted.
+	applied := cluster.NewBuffer(c)
+	if err := applied.Batch(rows); err != nil {
+		return nil, err
+	}
+	allNodes, err := node.NewNode(applied).AllNodes()
+	if err != nil {
+		return nil, err
+	}
+	nodeIDs := map[string]bool{}
+	walkPreOrder(allNodes, 0, func(n *node.Node, _ int) error {
+		nodeIDs[n.ID] = true
+		return nil
+	})
+	resourceRows := make([]sm.Row, 0, len(rows))
+	for _, row := range rows {
+		if nodeIDs[string(row.Bucket)] && string(row.Key) != dashboardType {
+			resourceRows = append(resourceRows, row)
+		}
+	}
+	added, err := resource.AddedCount(c, resourceRows)
+	if err != nil || len(added) == 0 {
+		return nil, err
+	}
+
+	usageRows := []sm.Row{}
+	for quotaNs, quota := range quotas {
+		n, err := allNodes.GetByNS(quotaNs)
+		if err != nil {
+			continue
+		}
+		delta := 0
+		walkPreOrder(n, 0, func(child *node.Node, _ int) error {
+			delta += added[child.ID]
+			return nil
+		})
+		if delta == 0 {
+			continue
+		}
+		usage, err := t.quotaUsage(context.Background(), c, n, quotaNs)
+		if err != nil {
+			return nil, err
+		}
+		if delta > 0 && usage+delta > quota {
+			t.logger.Errorf("resource count of ns %s will be %d, exceed the quota %d", quotaNs, usage+delta, quota)
+			return nil, common.ErrQuotaExceeded
+		}
+		usageRows = append(usageRows, sm.Row{Bucket: []byte(quotaBucket), Key: usageKey(quotaNs), Value: []byte(strconv.Itoa(usage + delta))})
+	}
+	return usageRows, nil
+}
+
+// resetQuotaUsage remove the saved usage of all ns, they are counted again when used.
+// It is called with quotaMu held after the resources are changed without writeWithQuota, e.g. the node is removed.
+func (t *Tree) resetQuotaUsage() error {
+	kv, err := t.cluster.ViewPrefix([]byte(quotaBucket), []byte(usagePrefix))
+	if err != nil {
+		return err
+	}
+	rows := []sm.Row{}
+	for k, v := range kv {
+		if len(v) != 0 {
+			rows = append(rows, sm.Row{Bucket: []byte(quotaBucket), Key: []byte(k), Value: []byte{}})
+		}
+	}
+	if len(rows) == 0 {
+		return nil
+	}
+	if err := t.cluster.Batch(rows); err != nil {
+		t.logger.Errorf("reset quota usage fail: %s", err.Error())
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,142 @@
+package tree
+
+import (
+	"context"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/lodastack/registry/common"
+	"github.com/lodastack/registry/model"
+	"github.com/lodastack/registry/tree/node"
+	"github.com/lodastack/registry/tree/test_sample"
+)
+
+func TestQuota(t *testing.T) {
+	s := test_sample.MustNewStore(t)
+	defer os.RemoveAll(s.Path())
+
+	if err := s.Open(true); err != nil {
+		t.Fatalf("failed to open single-node store: %s", err.Error())
+	}
+	defer s.Close(true)
+	s.WaitForLeader(10 * time.Second)
+	tree, _ := NewTree(s)
+
+	if _, err := tree.NewNode("testQuota", "comment", node.RootNode, node.NonLeaf); err != nil {
+		t.Fatalf("create nonleaf fail: %s", err.Error())
+	}
+	parentNs := "testQuota." + node.RootNode
+	if _, err := tree.NewNode("leaf", "comment", parentNs, node.Leaf); err != nil {
+		t.Fatalf("create leaf fail: %s", err.Error())
+	}
+	leafNs := "leaf." + parentNs
+	if _, err := tree.NewNode("leaf2", "comment", parentNs, node.Leaf); err != nil {
+		t.Fatalf("create leaf fail: %s", err.Error())
+	}
+	leaf2Ns := "leaf2." + parentNs
+
+	base, err := tree.GetQuotaUsage(context.Background(), parentNs)
+	if err != nil || base.Quota != 0 {
+		t.Fatalf("GetQuotaUsage not match with expect: %+v, error: %v", base, err)
+	}
+	if err := tree.SetQuota(parentNs, base.Usage+3); err != nil {
+		t.Fatalf("SetQuota fail: %s", err.Error())
+	}
+
+	// case 1: write under the quota of parent ns.
+	machine1 := model.NewResource(map[string]string{"hostname": "host1"})
+	machine2 := model.NewResource(map[string]string{"hostname": "host2"})
+	machine3 := model.NewResource(map[string]string{"hostname": "host3"})
+	machine4 := model.NewResource(map[string]string{"hostname": "host4"})
+	if err := tree.SetResource(leafNs, "machine", model.ResourceList{machine1, machine2}); err != nil {
+		t.Fatalf("SetResource under quota fail: %s", err.Error())
+	}
+	if err := tree.AppendResource(leafNs, "machine", machine3); err != nil {
+		t.Fatalf("AppendResource under quota fail: %s", err.Error())
+	}
+
+	// case 2: write exceed the quota.
+	if err := tree.AppendResource(leafNs, "machine", machine4); err != common.ErrQuotaExceeded {
+		t.Fatalf("AppendResource exceed quota not match with expect, error: %v", err)
+	}
+	if err := tree.SetResource(leafNs, "machine", model.ResourceList{machine1, machine2, machine3, machine4}); err != common.ErrQuotaExceeded {
+		t.Fatalf("SetResource exceed quota not match with expect, error: %v", err)
+	}
+
+	// case 3: shrink is allowed and the usage is updated.
+	if err := tree.SetResource(leafNs, "machine", model.ResourceList{machine1}); err != nil {
+		t.Fatalf("SetResource shrink fail: %s", err.Error())
+	}
+	if usage, err := tree.GetQuotaUsage(context.Background(), parentNs); err != nil || usage.Quota != base.Usage+3 || usage.Usage != base.Usage+1 {
+		t.Fatalf("GetQuotaUsage not match with expect: %+v, error: %v", usage, err)
+	}
+	if usages, err := tree.ListQuotaUsage(context.Background()); err != nil || len(usages) != 1 || usages[0].NS != parentNs {
+		t.Fatalf("ListQuotaUsage not match with expect: %+v, error: %v", usages, err)
+	}
+
+	// case 4: copy and tx are checked too, the resources in tx are counted before commit.
+	if _, err := tree.NewNode("other", "comment", node.RootNode, node.Leaf); err != nil {
+		t.Fatalf("create leaf fail: %s", err.Error())
+	}
+	otherNs := "other." + node.RootNode
+	if err := tree.SetResource(otherNs, "machine", model.ResourceList{machine2, machine3, machine4}); err != nil {
+		t.Fatalf("SetResource out of quota ns fail: %s", err.Error())
+	}
+	others, _ := tree.GetResourceList(otherNs, "machine")
+	otherIDs := []string{}
+	for _, r := range *others {
+		id, _ := r.ID()
+		otherIDs = append(otherIDs, id)
+	}
+	if err := tree.CopyResource(otherNs, leafNs, "machine", otherIDs...); err != common.ErrQuotaExceeded {
+		t.Fatalf("CopyResource exceed quota not match with expect, error: %v", err)
+	}
+	err = tree.Tx(func(tx TreeTx) error {
+		if err := tx.AppendResource(leafNs, "machine", machine2); err != nil {
+			return err
+		}
+		return tx.AppendResource(leafNs, "machine", machine3, machine4)
+	})
+	if err != common.ErrQuotaExceeded {
+		t.Fatalf("Tx exceed quota not match with expect, error: %v", err)
+	}
+	if usage, err := tree.GetQuotaUsage(context.Background(), parentNs); err != nil || usage.Usage != base.Usage+1 {
+		t.Fatalf("GetQuotaUsage after rejected write not match with expect: %+v, error: %v", usage, err)
+	}
+
+	// case 5: move inside the ns is allowed when the quota is full, the usage is saved with the write.
+	if err := tree.AppendResource(leafNs, "machine",
+		model.NewResource(map[string]string{"hostname": "host5"}),
+		model.NewResource(map[string]string{"hostname": "host6"})); err != nil {
+		t.Fatalf("AppendResource to the quota fail: %s", err.Error())
+	}
+	leafList, _ := tree.GetResourceList(leafNs, "machine")
+	moveID, _ := (*leafList)[0].ID()
+	if err := tree.MoveResource(leafNs, leaf2Ns, "machine", moveID); err != nil {
+		t.Fatalf("MoveResource inside quota ns fail: %s", err.Error())
+	}
+	if v, err := tree.cluster.View([]byte(quotaBucket), usageKey(parentNs)); err != nil || string(v) != strconv.Itoa(base.Usage+3) {
+		t.Fatalf("saved usage not match with expect: %s, error: %v", string(v), err)
+	}
+
+	// case 6: remove the quota.
+	if err := tree.SetQuota(parentNs, 0); err != nil {
+		t.Fatalf("remove quota fail: %s", err.Error())
+	}
+	if err := tree.AppendResource(leafNs, "machine", machine2, machine3, machine4); err != nil {
+		t.Fatalf("AppendResource without quota fail: %s", err.Error())
+	}
+	if usages, err := tree.ListQuotaUsage(context.Background()); err != nil || len(usages) != 0 {
+		t.Fatalf("ListQuotaUsage not match with expect: %+v, error: %v", usages, err)
+	}
+
+	// case 7: invalid quota and not exist ns.
+	if err := tree.SetQuota(parentNs, -1); err != common.ErrInvalidParam {
+		t.Fatalf("SetQuota with invalid quota not match with expect, error: %v", err)
+	}
+	if err := tree.SetQuota("notexist."+node.RootNode, 1); err != common.ErrNodeNotFound {
+		t.Fatalf("SetQuota of not exist ns not match with expect, error: %v", err)
+	}
+}
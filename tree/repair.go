@@ -0,0 +1,127 @@
+package tree
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/lodastack/registry/common"
+	"github.com/lodastack/registry/model"
+	"github.com/lodastack/registry/tree/codec"
+
+	sm "github.com/lodastack/store/model"
+)
+
+// corruptPrefix is the key prefix which bad value is quarantined under.
+const corruptPrefix = "_corrupt_"
+
+// ErrBackupKeyExist is returned if the backup key of the bad value is used,
+// the backup is never overwritten.
+var ErrBackupKeyExist = errors.New("backup key already exist")
+
+// RepairReport describe whether a stored value could be parsed,
+// and what was done to repair it.
+type RepairReport struct {
+	NodeID    string `json:"nodeid"`
+	ResType   string `json:"type"`
+	Size      int    `json:"size"`
+	Parseable bool   `json:"parseable"`
+	Error     string `json:"error,omitempty"`
+
+	// BackupKey is the key the bad value quarantined under, empty if not repaired.
+	BackupKey string `json:"backupkey,omitempty"`
+	Repaired  bool   `json:"repaired"`
+}
+
+// checkValue try to unmarshal the value by its type.
+// Dashboard is saved by the tree codec, other resource is saved as resource list byte.
+func checkValue(resType string, v []byte) error {
+	if len(v) == 0 {
+		return nil
+	}
+	if resType == dashboardType {
+		var dashboards []model.Dashboard
+		return codec.Decode(v, &dashboards)
+	}
+	rl := model.ResourceList{}
+	return rl.Unmarshal(v)
+}
+
+func isCorruptKey(key string) bool {
+	return strings.HasPrefix(key, corruptPrefix)
+}
+
+// PreviewRepair report whether the value of the ns/resType is parseable without change it.
+func (t *Tree) PreviewRepair(ns, resType string) (RepairReport, error) {
+	report := RepairReport{ResType: resType}
+	if resType == "" || isCorruptKey(resType) {
+		return report, common.ErrInvalidParam
+	}
+	nodeID, err := t.getNodeIDByNS(ns)
+	if err != nil {
+		return report, err
+	}
+	report.NodeID = nodeID
+
+	v, err := t.getByteFromStore(nodeID, resType)
+	if err != nil {
+		return report, err
+	}
+	report.Size = len(v)
+	if err := checkValue(resType, v); err != nil {
+		report.Error = err.Error()
+		return report, nil
+	}
+	report.Parseable = true
+	return report, nil
+}
+
+// RepairValue salvage the value of the ns/resType if it cannot be unmarshaled.
+// The bad value is quarantined under a backup key in the same node bucket,
+// and the value is reset to empty so that the ns become usable again.
+// Nothing will be changed if the value is parseable.
+func (t *Tree) RepairValue(ns, resType string) error {
+	_, err := t.RepairValueWithReport(ns, resType)
+	return err
+}
+
+// RepairValueWithReport is RepairValue which return the report of what was done.
+func (t *Tree) RepairValueWithReport(ns, resType string) (RepairReport, error) {
+	report, err := t.PreviewRepair(ns, resType)
+	if err != nil || report.Parseable {
+		return report, err
+	}
+
+	v, err := t.getByteFromStore(report.NodeID, resType)
+	if err != nil {
+		return report, err
+	}
+	// quarantine the bad value and reset the value in one batch,
+	// so the bad value is never lost without the backup.
+	backupKey := fmt.Sprintf("%s%s_%d", corruptPrefix, resType, time.Now().UnixNano())
+	if exist, err := t.getByteFromStore(report.NodeID, backupKey); err != nil {
+		return report, err
+	} else if len(exist) != 0 {
+		t.logger.Errorf("backup key %s of ns %s already exist", backupKey, ns)
+		return report, ErrBackupKeyExist
+	}
+	rows := []sm.Row{
+		{Bucket: []byte(report.NodeID), Key: []byte(backupKey), Value: v},
+		{Bucket: []byte(report.NodeID), Key: []byte(resType), Value: []byte{}},
+	}
+	// the saved quota usage may not match the reset value, so it is counted again.
+	t.quotaMu.Lock()
+	defer t.quotaMu.Unlock()
+	if err := t.cluster.Batch(rows); err != nil {
+		t.logger.Errorf("quarantine and reset bad value fail, ns: %s, type: %s, error: %s", ns, resType, err.Error())
+		return report, err
+	}
+	if err := t.resetQuotaUsage(); err != nil {
+		return report, err
+	}
+	report.BackupKey, report.Repaired = backupKey, true
+	t.logger.Infof("repair value of ns %s type %s, %d bytes quarantined under %s, parse error: %s",
+		ns, resType, report.Size, backupKey, report.Error)
+	return report, nil
+}
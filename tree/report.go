@@ -3,6 +3,7 @@ package tree
 import (
 	"encoding/json"
 	"sync"
+	"time"
 
 	"github.com/lodastack/registry/common"
 	"github.com/lodastack/registry/model"
@@ -12,6 +13,10 @@ import (
 type ReportInfo struct {
 	sync.RWMutex
 	ReportInfo reportMap
+
+	// lastSeen is the time this registry node receive the last report of the hostname,
+	// it is only in memory and not replicated.
+	lastSeen map[string]time.Time
 }
 
 type reportMap map[string]model.Report
@@ -38,8 +43,10 @@ func (t *Tree) AgentReport(info model.Report) error {
 	}
 	if info.OldHostname != info.NewHostname {
 		delete(t.reports.ReportInfo, info.OldHostname)
+		delete(t.reports.lastSeen, info.OldHostname)
 	}
 	t.reports.ReportInfo[info.NewHostname] = info
+	t.reports.lastSeen[info.NewHostname] = time.Now()
 	return nil
 }
 
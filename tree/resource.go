@@ -1,10 +1,28 @@
 package tree
 
 import (
+	"context"
+	"sort"
+	"strings"
+
+	"github.com/lodastack/registry/common"
 	"github.com/lodastack/registry/model"
+	"github.com/lodastack/registry/tree/cluster"
+	"github.com/lodastack/registry/tree/codec"
+	"github.com/lodastack/registry/tree/node"
 )
 
+// DanglingRef is the dashboard target which may still reference the moved resource.
+type DanglingRef struct {
+	NS        string       `json:"ns"`
+	Dashboard int          `json:"dashboard"`
+	Panel     int          `json:"panel"`
+	Target    int          `json:"target"`
+	Data      model.Target `json:"data"`
+}
+
 // SetResource set the resource list to the ns.
+// Return ErrQuotaExceeded if the resource count exceed the quota of the ns or its parent ns.
 func (t *Tree) SetResource(ns, resType string, l model.ResourceList) error {
 	return t.resource.SetResource(ns, resType, l)
 }
@@ -14,6 +32,41 @@ func (t *Tree) GetResource(ns, resourceType string, stringresID ...string) ([]mo
 	return t.resource.GetResource(ns, resourceType, stringresID...)
 }
 
+// GetResourceProjected return the resources of the ns with only the fields,
+// return all resources of the type if no resID is provided. The missing field is not returned.
+func (t *Tree) GetResourceProjected(ns, resType string, fields []string, resID ...string) ([]map[string]string, error) {
+	var resources []model.Resource
+	if len(resID) == 0 {
+		rl, err := t.resource.GetResourceList(ns, resType)
+		if err != nil {
+			return nil, err
+		}
+		if rl != nil {
+			resources = *rl
+		}
+	} else {
+		var err error
+		if resources, err = t.resource.GetResource(ns, resType, resID...); err != nil {
+			return nil, err
+		}
+	}
+	return project(resources, fields), nil
+}
+
+// project return the properties of the resources in the fields.
+func project(resources []model.Resource, fields []string) []map[string]string {
+	result := make([]map[string]string, len(resources))
+	for i, res := range resources {
+		result[i] = make(map[string]string, len(fields))
+		for _, field := range fields {
+			if v, ok := res.ReadProperty(field); ok {
+				result[i][field] = v
+			}
+		}
+	}
+	return result
+}
+
 // GetResourceList return a type resource list of a node.
 func (t *Tree) GetResourceList(ns, resourceType string) (*model.ResourceList, error) {
 	return t.resource.GetResourceList(ns, resourceType)
@@ -24,8 +77,14 @@ func (t *Tree) UpdateResource(ns, resType, resID string, updateMap map[string]st
 	return t.resource.UpdateResource(ns, resType, resID, updateMap)
 }
 
-// AppendResource append resources to a ns.
+// AppendResource append resources to a ns, the missing properties are set by the defaults of resource type.
+// The quota is checked after the defaults are set.
+// Return ErrQuotaExceeded if the resource count exceed the quota of the ns or its parent ns.
 func (t *Tree) AppendResource(ns, resType string, appendRes ...model.Resource) error {
+	appendRes, err := t.withDefaults(resType, appendRes...)
+	if err != nil {
+		return err
+	}
 	return t.resource.AppendResource(ns, resType, appendRes...)
 }
 
@@ -34,6 +93,85 @@ func (t *Tree) MoveResource(oldNs, newNs, resType string, resourceIDs ...string)
 	return t.resource.MoveResource(oldNs, newNs, resType, resourceIDs...)
 }
 
+// MoveResourceWithRefs move the resources to newNs and keep their ID,
+// return the dashboard targets which query oldNs by the pk of moved resource, e.g. hostname.
+// The targets are not changed because they may query other resource of oldNs too, the caller should fix them.
+func (t *Tree) MoveResourceWithRefs(oldNs, newNs, resType string, resourceIDs ...string) ([]DanglingRef, error) {
+	moved, err := t.resource.MoveResourceKeepID(oldNs, newNs, resType, resourceIDs...)
+	if err != nil {
+		return nil, err
+	}
+	pk := model.PkProperty[resType]
+	pkValues := []string{}
+	for _, res := range moved {
+		if pkValue, _ := res.ReadProperty(pk); pk != "" && pkValue != "" {
+			pkValues = append(pkValues, pkValue)
+		}
+	}
+	if len(pkValues) == 0 {
+		return nil, nil
+	}
+	return t.danglingRefs(oldNs, pkValues)
+}
+
+// danglingRefs return the dashboard targets of all ns which query ns and its where has any of the pk values.
+func (t *Tree) danglingRefs(ns string, pkValues []string) ([]DanglingRef, error) {
+	allNodes, err := t.AllNodes()
+	if err != nil {
+		return nil, err
+	}
+	nsIDs, err := allNodes.Walk(func(n *node.Node, childReturn map[string]string) (map[string]string, error) {
+		result := map[string]string{n.Name: n.ID}
+		for childNs, id := range childReturn {
+			result[childNs+node.NodeDeli+n.Name] = id
+		}
+		return result, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	refs := []DanglingRef{}
+	for dashboardNs, nodeID := range nsIDs {
+		v, err := t.getByteFromStore(nodeID, dashboardType)
+		if err != nil || len(v) == 0 {
+			continue
+		}
+		var dashboards model.DashboardData
+		if err := codec.Decode(v, &dashboards); err != nil {
+			t.logger.Errorf("decode dashboard of ns %s fail: %s", dashboardNs, err.Error())
+			continue
+		}
+		for d, dashboard := range dashboards {
+			for p, panel := range dashboard.Panels {
+				for i, target := range panel.Targets {
+					if target.Ns != ns || !hasToken(target.Where, pkValues) {
+						continue
+					}
+					refs = append(refs, DanglingRef{NS: dashboardNs, Dashboard: d, Panel: p, Target: i, Data: target})
+				}
+			}
+		}
+	}
+	return refs, nil
+}
+
+// hasToken return whether the where has any of the values as a whole token,
+// e.g. "host = 'host1'" has host1 but not host10. The where is split by comma, space, '=' and quote.
+func hasToken(where string, values []string) bool {
+	tokens := strings.FieldsFunc(where, func(c rune) bool {
+		return c == ',' || c == ' ' || c == '=' || c == '\'' || c == '"'
+	})
+	for _, token := range tokens {
+		for _, v := range values {
+			if token == v {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 // SearchResource search any preperty resource in the ns and its child ns.
 func (t *Tree) SearchResource(ns, resType string, search model.ResourceSearch) (map[string]*model.ResourceList, error) {
 	return t.resource.SearchResource(ns, resType, search)
@@ -44,7 +182,137 @@ func (t *Tree) CopyResource(fromNs, toNs, resType string, resourceIDs ...string)
 	return t.resource.CopyResource(fromNs, toNs, resType, resourceIDs...)
 }
 
+// WalkResource call fn with the resources of the ns one by one without building the resource list.
+func (t *Tree) WalkResource(ns, resType string, fn func(model.Resource) error) error {
+	return t.resource.WalkResource(ns, resType, fn)
+}
+
+// GetResourceByIndex return the resources of the ns whose property is the value by the secondary index.
+func (t *Tree) GetResourceByIndex(ns, resType, property, value string) ([]model.Resource, error) {
+	return t.resource.GetResourceByIndex(ns, resType, property, value)
+}
+
+// RekeyResource change the resource ID from oldID to newID, reject if newID already exist.
+func (t *Tree) RekeyResource(ns, resType, oldID, newID string) error {
+	return t.resource.RekeyResource(ns, resType, oldID, newID)
+}
+
 // RemoveResource remove one resource from a node.
 func (t *Tree) RemoveResource(ns, resourceType string, resID ...string) error {
 	return t.resource.RemoveResource(ns, resourceType, resID...)
 }
+
+// ResourceTypes return the resource types stored in the ns, include the template and dashboard.
+// The value quarantined by RepairValue is not included.
+func (t *Tree) ResourceTypes(ns string) ([]string, error) {
+	nodeID, err := t.getNodeIDByNS(ns)
+	if err != nil {
+		return nil, err
+	}
+	kv, err := t.cluster.ViewPrefix([]byte(nodeID), []byte{})
+	if err != nil {
+		t.logger.Errorf("view keys of ns %s fail: %s", ns, err.Error())
+		return nil, err
+	}
+	resTypes := make([]string, 0, len(kv))
+	for k := range kv {
+		if isCorruptKey(k) {
+			continue
+		}
+		resTypes = append(resTypes, k)
+	}
+	sort.Strings(resTypes)
+	return resTypes, nil
+}
+
+// CountResources return the resource type - count map of the ns and all its child ns.
+// The resources are counted by model.CountResources on the stored bytes without unmarshal,
+// the template is not counted. Each node is read by one ViewPrefix, the store has no read across
+// the buckets of nodes, so the counts of different nodes may be read at different time.
+// Return ErrNodeNotFound if the ns not exist, or ctx.Err() if ctx is done before finished.
+func (t *Tree) CountResources(ctx context.Context, ns string) (map[string]int, error) {
+	counts := map[string]int{}
+	err := t.Walk(ns, func(n *node.Node, depth int) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		return t.countNode(t.cluster, n.ID, counts)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return counts, nil
+}
+
+// countNode add the count of each type resource in the node read from c to counts.
+// The template and dashboard is not counted.
+func (t *Tree) countNode(c cluster.Inf, nodeID string, counts map[string]int) error {
+	kv, err := c.ViewPrefix([]byte(nodeID), []byte{})
+	if err != nil {
+		t.logger.Errorf("view resource of node %s fail: %s", nodeID, err.Error())
+		return err
+	}
+	for k, v := range kv {
+		if isCorruptKey(k) || k == dashboardType || strings.HasPrefix(k, model.TemplatePrefix) || len(v) == 0 {
+			continue
+		}
+		count, err := model.CountResources(v)
+		if err != nil {
+			t.logger.Errorf("count resource %s of node %s fail: %s", k, nodeID, err.Error())
+			return err
+		}
+		counts[k] += count
+	}
+	return nil
+}
+
+// WalkSubtree call visit with the node and its resource type - resource list map,
+// for the ns and all its child node, parent node first.
+// visit must not write to the tree. Walking stop if visit return error, or return ctx.Err() if ctx is done.
+// NOTE: it is not a snapshot of the subtree. Each node is read by its own ViewPrefix when visited,
+// the change made during the walk may be seen by the later nodes, even the same resource moved between
+// two nodes may be visited twice or not at all. A single read of the subtree need the store support.
+func (t *Tree) WalkSubtree(ctx context.Context, ns string, visit func(n *node.Node, resources map[string][]model.Resource) error) error {
+	allNodes, err := t.AllNodes()
+	if err != nil {
+		return err
+	}
+	n, err := allNodes.GetByNS(ns)
+	if err != nil {
+		t.logger.Errorf("get node of ns %s fail: %s", ns, err.Error())
+		return err
+	}
+	return t.walkNode(ctx, n, visit)
+}
+
+func (t *Tree) walkNode(ctx context.Context, n *node.Node, visit func(n *node.Node, resources map[string][]model.Resource) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	kv, err := t.cluster.ViewPrefix([]byte(n.ID), []byte{})
+	if err != nil {
+		t.logger.Errorf("view resource of node %s fail: %s", n.ID, err.Error())
+		return err
+	}
+	resources := make(map[string][]model.Resource, len(kv))
+	for k, v := range kv {
+		if isCorruptKey(k) || k == dashboardType {
+			continue
+		}
+		rl := model.ResourceList{}
+		if err := rl.Unmarshal(v); err != nil && err != common.ErrEmptyResource {
+			t.logger.Errorf("unmarshal resource %s of node %s fail: %s", k, n.ID, err.Error())
+			return err
+		}
+		resources[k] = rl
+	}
+	if err := visit(n, resources); err != nil {
+		return err
+	}
+	for _, child := range n.Children {
+		if err := t.walkNode(ctx, child, visit); err != nil {
+			return err
+		}
+	}
+	return nil
+}
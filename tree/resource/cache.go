@@ -0,0 +1,124 @@
+package resource
+
+import (
+	"bytes"
+	"container/list"
+	"sync"
+
+	"github.com/lodastack/registry/model"
+)
+
+// listCache cache the unmarshaled resource list by nodeID/resType,
+// so that the hot resource list is not unmarshaled at every read.
+// The raw byte is saved with the list and compared with the byte read from store,
+// so a list changed by other path (e.g. the raft peer) is never returned from cache.
+// The list is invalidated when it is changed by resource method.
+type listCache struct {
+	mu    sync.Mutex
+	size  int
+	ll    *list.List
+	items map[string]*list.Element
+}
+
+type cacheEntry struct {
+	key string
+	raw []byte
+	rl  model.ResourceList
+}
+
+// newListCache return a cache holding at most size resource list, return nil if size is not positive.
+func newListCache(size int) *listCache {
+	if size <= 0 {
+		return nil
+	}
+	return &listCache{size: size, ll: list.New(), items: make(map[string]*list.Element)}
+}
+
+func cacheKey(nodeID, resType string) string {
+	return nodeID + "/" + resType
+}
+
+// get return the copy of cached resource list if the raw byte is not changed.
+func (c *listCache) get(nodeID, resType string, raw []byte) (*model.ResourceList, bool) {
+	if c == nil {
+		return nil, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.items[cacheKey(nodeID, resType)]
+	if !ok {
+		return nil, false
+	}
+	entry := e.Value.(*cacheEntry)
+	if !bytes.Equal(entry.raw, raw) {
+		c.remove(e)
+		return nil, false
+	}
+	c.ll.MoveToFront(e)
+	rl := copyResourceList(entry.rl)
+	return &rl, true
+}
+
+// set save the copy of resource list and its raw byte to cache.
+func (c *listCache) set(nodeID, resType string, raw []byte, rl model.ResourceList) {
+	if c == nil {
+		return
+	}
+	entry := &cacheEntry{
+		key: cacheKey(nodeID, resType),
+		raw: append([]byte{}, raw...),
+		rl:  copyResourceList(rl)}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.items[entry.key]; ok {
+		e.Value = entry
+		c.ll.MoveToFront(e)
+		return
+	}
+	c.items[entry.key] = c.ll.PushFront(entry)
+	for c.ll.Len() > c.size {
+		c.remove(c.ll.Back())
+	}
+}
+
+// invalidate remove the resource list of nodeID/resType from cache.
+func (c *listCache) invalidate(nodeID, resType string) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.items[cacheKey(nodeID, resType)]; ok {
+		c.remove(e)
+	}
+}
+
+func (c *listCache) len() int {
+	if c == nil {
+		return 0
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.ll.Len()
+}
+
+func (c *listCache) remove(e *list.Element) {
+	c.ll.Remove(e)
+	delete(c.items, e.Value.(*cacheEntry).key)
+}
+
+func copyResourceList(rl model.ResourceList) model.ResourceList {
+	if rl == nil {
+		return nil
+	}
+	cp := make(model.ResourceList, len(rl))
+	for i, r := range rl {
+		res := make(model.Resource, len(r))
+		for k, v := range r {
+			res[k] = v
+		}
+		cp[i] = res
+	}
+	return cp
+}
@@ -0,0 +1,55 @@
+package resource
+
+import (
+	"testing"
+
+	"github.com/lodastack/registry/model"
+)
+
+func TestListCache(t *testing.T) {
+	c := newListCache(2)
+	rl := model.ResourceList{{"host": "127.0.0.1"}, {"host": "127.0.0.2"}}
+	c.set("1", "machine", []byte("v1"), rl)
+
+	// modify the origin list should not change the cache.
+	rl[0]["host"] = "changed"
+	cached, ok := c.get("1", "machine", []byte("v1"))
+	if !ok || len(*cached) != 2 || (*cached)[0]["host"] != "127.0.0.1" {
+		t.Fatalf("get from cache not match with expect: %v, %v", ok, cached)
+	}
+	// modify the list get from cache should not change the cache.
+	(*cached)[1]["host"] = "changed"
+	if cached, _ = c.get("1", "machine", []byte("v1")); (*cached)[1]["host"] != "127.0.0.2" {
+		t.Fatalf("cache is changed by caller: %v", cached)
+	}
+
+	// the list is dropped if the raw byte is changed.
+	if _, ok := c.get("1", "machine", []byte("v2")); ok || c.len() != 0 {
+		t.Fatalf("get stale list from cache, not match with expect")
+	}
+
+	c.set("1", "machine", []byte("v1"), rl)
+	c.invalidate("1", "machine")
+	if _, ok := c.get("1", "machine", []byte("v1")); ok {
+		t.Fatalf("get invalidated list from cache, not match with expect")
+	}
+
+	// the least recently used list is removed if the cache is full.
+	c.set("1", "machine", []byte("v1"), rl)
+	c.set("2", "machine", []byte("v1"), rl)
+	c.get("1", "machine", []byte("v1"))
+	c.set("3", "machine", []byte("v1"), rl)
+	if _, ok := c.get("2", "machine", []byte("v1")); ok || c.len() != 2 {
+		t.Fatalf("cache is not bounded, len: %d", c.len())
+	}
+	if _, ok := c.get("1", "machine", []byte("v1")); !ok {
+		t.Fatalf("recently used list is removed, not match with expect")
+	}
+
+	// disabled cache.
+	disabled := newListCache(0)
+	disabled.set("1", "machine", []byte("v1"), rl)
+	if _, ok := disabled.get("1", "machine", []byte("v1")); ok {
+		t.Fatalf("get from disabled cache, not match with expect")
+	}
+}
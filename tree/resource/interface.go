@@ -5,10 +5,11 @@ package resource
 // Leaf node have resource; Nonleaf node have resource template which used when create child node.
 
 import (
-	"github.com/lodastack/log"
 	"github.com/lodastack/registry/model"
 	"github.com/lodastack/registry/tree/cluster"
 	"github.com/lodastack/registry/tree/node"
+
+	sm "github.com/lodastack/store/model"
 )
 
 // Inf is the interface resource have.
@@ -19,6 +20,12 @@ type Inf interface {
 	// GetResource return the one resource of the ns.
 	GetResource(ns, resType string, resourceID ...string) ([]model.Resource, error)
 
+	// WalkResource call fn with the resources of the ns one by one without building the resource list.
+	WalkResource(ns, resType string, fn func(model.Resource) error) error
+
+	// GetResourceByIndex return the resources of the ns whose property is the value by the secondary index.
+	GetResourceByIndex(ns, resType, property, value string) ([]model.Resource, error)
+
 	// SetResource set the resource list to the ns.
 	SetResource(ns, resType string, rl model.ResourceList) error
 
@@ -34,6 +41,12 @@ type Inf interface {
 	// MoveResource move one resource fo an other ns, the resouce will be removed from the old ns.
 	MoveResource(oldNs, newNs, resType string, resourceIDs ...string) error
 
+	// RekeyResource change the resource ID from oldID to newID, reject if newID already exist.
+	RekeyResource(ns, resType, oldID, newID string) error
+
+	// MoveResourceKeepID move the resources to an other ns and keep their ID, return the moved resources.
+	MoveResourceKeepID(oldNs, newNs, resType string, resourceIDs ...string) ([]model.Resource, error)
+
 	// CopyResource copy one resource from one ns to the other ns, the resource will still exist in the old ns.
 	CopyResource(fromNs, toNs, resType string, resourceIDs ...string) error
 
@@ -42,13 +55,29 @@ type Inf interface {
 	SearchResource(ns, resType string, search model.ResourceSearch) (map[string]*model.ResourceList, error)
 }
 
+// QuotaFunc is called with the rows of every resource write instead of writing them to c,
+// it check the quota by the rows and write the rows with the change of quota usage by write in one batch.
+// The write is rejected if it return error.
+type QuotaFunc func(c cluster.Inf, rows []sm.Row, write func([]sm.Row) error) error
+
 type resourceMethod struct {
 	cluster cluster.Inf
 	node    node.Inf
-	logger  *log.Logger
+	logger  *model.Logger
+
+	// cache is nil if the cache is not enabled.
+	cache *listCache
+	// quota is nil if the quota is not checked.
+	quota QuotaFunc
+}
+
+// NewResource return the reource interface, the resource write is checked by quota if it is not nil.
+func NewResource(cluster cluster.Inf, node node.Inf, logger *model.Logger, quota QuotaFunc) Inf {
+	return NewCachedResource(cluster, node, logger, 0, quota)
 }
 
-// NewResource return the reource interface.
-func NewResource(cluster cluster.Inf, node node.Inf, logger *log.Logger) Inf {
-	return &resourceMethod{cluster: cluster, node: node, logger: logger}
+// NewCachedResource return the reource interface which cache at most cacheSize unmarshaled resource list.
+// The cache is disabled if cacheSize is not positive.
+func NewCachedResource(cluster cluster.Inf, node node.Inf, logger *model.Logger, cacheSize int, quota QuotaFunc) Inf {
+	return &resourceMethod{cluster: cluster, node: node, logger: logger, cache: newListCache(cacheSize), quota: quota}
 }
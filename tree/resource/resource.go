@@ -1,6 +1,7 @@
 package resource
 
 import (
+	"encoding/json"
 	"errors"
 	"strings"
 
@@ -8,11 +9,19 @@ import (
 	"github.com/lodastack/registry/limit"
 	"github.com/lodastack/registry/model"
 	"github.com/lodastack/registry/tree/cluster"
+	"github.com/lodastack/registry/tree/node"
+
+	sm "github.com/lodastack/store/model"
 )
 
+// IndexBucket save the secondary index of resource, the key is nodeID/resType/property.
+const IndexBucket = "index"
+
 var (
 	ErrNotFound           = errors.New("not found")
 	ErrEmtpyResource      = errors.New("empty resource")
+	ErrResourceIDExist    = errors.New("resource id already exist")
+	ErrResourcePkExist    = errors.New("resource pk already exist")
 	defaultResourceWorker = 100
 )
 
@@ -22,21 +31,38 @@ func (r *resourceMethod) getResourceList(nodeID, resourceType string) (*model.Re
 	if err != nil {
 		return nil, err
 	}
+	if rl, ok := r.cache.get(nodeID, resourceType, resByte); ok {
+		return rl, nil
+	}
 	rl := new(model.ResourceList)
 	err = rl.Unmarshal(resByte)
 	if err != nil && err != common.ErrEmptyResource {
 		r.logger.Errorf("unmarshal resource fail, error: %s, data: %s:", err, string(resByte))
 		return nil, err
 	}
+	r.cache.set(nodeID, resourceType, resByte, *rl)
 	return rl, nil
 }
 
+// return the node ID of ns, return NodeTypeError if the node could not have the resource type.
+func (r *resourceMethod) getNodeIDAllowResource(ns, resourceType string) (string, error) {
+	node, err := r.node.GetNodeByNS(ns)
+	if err != nil {
+		r.logger.Errorf("GetNodeByNS fail: %s", err.Error())
+		return "", err
+	}
+	if err := node.CheckResource(resourceType); err != nil {
+		r.logger.Errorf("operate resource %s of ns %s fail: %s", resourceType, ns, err.Error())
+		return "", err
+	}
+	return node.ID, nil
+}
+
 // return the resource list at form of []byte by nodeId/resource type.
 // NOTE: return error ErrEmtpyResource if the resource list in store is emtpy.
 func (r *resourceMethod) getResourceListByte(ns, resourceType string) (nodeID string, resByte []byte, err error) {
-	nodeID, err = r.node.GetNodeIDByNS(ns)
+	nodeID, err = r.getNodeIDAllowResource(ns, resourceType)
 	if err != nil {
-		r.logger.Errorf("getNodeIDByNS fail: %s", err.Error())
 		return "", nil, err
 	}
 	resOldByte, err := r.cluster.View([]byte(nodeID), []byte(resourceType))
@@ -51,6 +77,154 @@ func (r *resourceMethod) getResourceListByte(ns, resourceType string) (nodeID st
 	return nodeID, resByte, nil
 }
 
+// indexKey return the key of the secondary index in IndexBucket.
+func indexKey(nodeID, resType, property string) []byte {
+	return []byte(nodeID + "/" + resType + "/" + property)
+}
+
+// indexed return whether the property of the resource type has secondary index registered.
+func indexed(resType, property string) bool {
+	for _, p := range model.IndexProperty[resType] {
+		if p == property {
+			return true
+		}
+	}
+	return false
+}
+
+// listRows return the row of the resource list and the rows of its secondary index.
+// The index of the property not registered any more, or of the removed resource list, is removed,
+// otherwise it would be stale when the property is registered again.
+func (r *resourceMethod) listRows(nodeID, resType string, resByte []byte) ([]sm.Row, error) {
+	rows := []sm.Row{{Bucket: []byte(nodeID), Key: []byte(resType), Value: resByte}}
+	oldIndex, err := r.cluster.ViewPrefix([]byte(IndexBucket), indexKey(nodeID, resType, ""))
+	if err != nil {
+		r.logger.Errorf("view index of %s/%s fail: %s", nodeID, resType, err.Error())
+		return nil, err
+	}
+	for key, v := range oldIndex {
+		property := strings.TrimPrefix(key, string(indexKey(nodeID, resType, "")))
+		if len(v) != 0 && (len(resByte) == 0 || !indexed(resType, property)) {
+			rows = append(rows, sm.Row{Bucket: []byte(IndexBucket), Key: []byte(key), Value: []byte{}})
+		}
+	}
+	properties := model.IndexProperty[resType]
+	if len(properties) == 0 || len(resByte) == 0 {
+		return rows, nil
+	}
+	rl := model.ResourceList{}
+	if err := rl.Unmarshal(resByte); err != nil && err != common.ErrEmptyResource {
+		r.logger.Errorf("unmarshal resource fail when index, error: %s", err)
+		return nil, err
+	}
+	for _, property := range properties {
+		index := map[string][]string{}
+		for _, res := range rl {
+			value, _ := res.ReadProperty(property)
+			if value == "" {
+				continue
+			}
+			id, _ := res.ID()
+			index[value] = append(index[value], id)
+		}
+		indexByte, err := json.Marshal(index)
+		if err != nil {
+			return nil, err
+		}
+		rows = append(rows, sm.Row{Bucket: []byte(IndexBucket), Key: indexKey(nodeID, resType, property), Value: indexByte})
+	}
+	return rows, nil
+}
+
+// setResourceByte save the resource list and its secondary index in one batch.
+func (r *resourceMethod) setResourceByte(nodeID, resType string, resByte []byte) error {
+	r.cache.invalidate(nodeID, resType)
+	rows, err := r.listRows(nodeID, resType, resByte)
+	if err != nil {
+		return err
+	}
+	if len(rows) == 1 && r.quota == nil {
+		return cluster.SetByte(r.cluster, nodeID, resType, resByte)
+	}
+	return r.batch(rows)
+}
+
+// batch write the rows of resource list in one batch, by the quota func if the quota is checked.
+func (r *resourceMethod) batch(rows []sm.Row) error {
+	if r.quota == nil {
+		return r.cluster.Batch(rows)
+	}
+	return r.quota(r.cluster, rows, r.cluster.Batch)
+}
+
+// AddedCount return the nodeID - count of resource added map if the rows are written to c,
+// the count is negative if the resources are removed and the node not changed is not in the map.
+// The template and the index rows are not counted.
+func AddedCount(c cluster.Inf, rows []sm.Row) (map[string]int, error) {
+	added := map[string]int{}
+	for _, row := range rows {
+		if string(row.Bucket) == IndexBucket || strings.HasPrefix(string(row.Key), model.TemplatePrefix) {
+			continue
+		}
+		oldByte, err := c.View(row.Bucket, row.Key)
+		if err != nil {
+			return nil, err
+		}
+		oldCount, err := model.CountResources(oldByte)
+		if err != nil {
+			return nil, err
+		}
+		newCount, err := model.CountResources(row.Value)
+		if err != nil {
+			return nil, err
+		}
+		if newCount != oldCount {
+			added[string(row.Bucket)] += newCount - oldCount
+		}
+	}
+	return added, nil
+}
+
+// GetResourceByIndex return the resources of the ns whose property is the value by the secondary index.
+// Search the resource list if the index is not registered or not built yet.
+func (r *resourceMethod) GetResourceByIndex(ns, resType, property, value string) ([]model.Resource, error) {
+	nodeID, err := r.getNodeIDAllowResource(ns, resType)
+	if err != nil {
+		return nil, err
+	}
+	// The index is not used if the property is not registered any more, it is not updated since then.
+	indexByte := []byte{}
+	if indexed(resType, property) {
+		if indexByte, err = r.cluster.View([]byte(IndexBucket), indexKey(nodeID, resType, property)); err != nil {
+			r.logger.Errorf("view index of %s/%s fail: %s", resType, property, err.Error())
+			return nil, err
+		}
+	}
+	rl, err := r.getResourceList(nodeID, resType)
+	if err != nil {
+		return nil, err
+	}
+	if len(indexByte) == 0 {
+		result := []model.Resource{}
+		for _, res := range *rl {
+			if v, _ := res.ReadProperty(property); v == value {
+				result = append(result, res)
+			}
+		}
+		return result, nil
+	}
+
+	index := map[string][]string{}
+	if err := json.Unmarshal(indexByte, &index); err != nil {
+		r.logger.Errorf("unmarshal index of %s/%s fail: %s", resType, property, err.Error())
+		return nil, err
+	}
+	if len(index[value]) == 0 {
+		return []model.Resource{}, nil
+	}
+	return rl.Get(model.IdKey, index[value]...)
+}
+
 // GetResource return the Resource list by ns/resourceType.
 // If the node is nonleaf node, return the resource list of all its leaf child node.
 func (r *resourceMethod) GetResourceList(ns string, resourceType string) (*model.ResourceList, error) {
@@ -83,6 +257,38 @@ func (r *resourceMethod) GetResourceList(ns string, resourceType string) (*model
 	return &allResourceList, nil
 }
 
+// WalkResource call fn with the resources of the ns one by one, which are unmarshaled from the stored byte.
+// If the node is nonleaf node, walk the resource of all its leaf child node one node after another.
+// NOTE: the stored byte of one node is read at once, the cluster could only return the copy of a value.
+func (r *resourceMethod) WalkResource(ns, resType string, fn func(model.Resource) error) error {
+	n, err := r.node.GetNodeByNS(ns)
+	if err != nil {
+		return err
+	}
+	nodeIDs := []string{n.ID}
+	if !n.AllowResource(resType) {
+		if nodeIDs, err = n.LeafChildIDs(); err != nil {
+			if err == common.ErrNoLeafChild {
+				return nil
+			}
+			return err
+		}
+	}
+	for _, nodeID := range nodeIDs {
+		resByte, err := r.cluster.View([]byte(nodeID), []byte(resType))
+		if err != nil {
+			return err
+		}
+		if len(resByte) == 0 {
+			continue
+		}
+		if err := model.WalkResources(resByte, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // Get Resource by ns/resource type/resource ID.
 func (r *resourceMethod) GetResource(ns, resType string, resID ...string) ([]model.Resource, error) {
 	l, err := r.GetResourceList(ns, resType)
@@ -103,8 +309,9 @@ func (r *resourceMethod) SetResource(ns, resType string, rl model.ResourceList)
 		r.logger.Errorf("Get node by ns(%s) fail", ns)
 		return common.ErrGetNode
 	}
-	if !node.AllowResource(resType) {
-		return common.ErrSetResourceToLeaf
+	if err := node.CheckResource(resType); err != nil {
+		r.logger.Errorf("set resource %s to ns %s fail: %s", resType, ns, err.Error())
+		return err
 	}
 
 	var resStore []byte
@@ -114,7 +321,7 @@ func (r *resourceMethod) SetResource(ns, resType string, rl model.ResourceList)
 		return err
 	}
 
-	return cluster.SetByte(r.cluster, node.ID, resType, resStore)
+	return r.setResourceByte(node.ID, resType, resStore)
 }
 
 // UpdateResource One Resource by ns/resource type/resource ID/update map.
@@ -130,7 +337,7 @@ func (r *resourceMethod) UpdateResource(ns, resType, resID string, updateMap map
 		r.logger.Errorf("UpdateResource fail becource update error: %s", err.Error())
 		return err
 	}
-	return cluster.SetByte(r.cluster, nodeID, resType, resNewByte)
+	return r.setResourceByte(nodeID, resType, resNewByte)
 }
 
 // AppendResource one resource to ns.
@@ -145,15 +352,13 @@ func (r *resourceMethod) AppendResource(ns, resType string, appendRes ...model.R
 		r.logger.Errorf("AppendResources error, length of resOld: %d, appendRes: %+v, error: %s", len(resOldByte), appendRes, err.Error())
 		return err
 	}
-	err = cluster.SetByte(r.cluster, nodeID, resType, resByte)
-	return err
+	return r.setResourceByte(nodeID, resType, resByte)
 }
 
 // DeleteResource remove a resource by ns/resTYpe/resID.
 func (r *resourceMethod) RemoveResource(ns, resType string, resID ...string) error {
-	nodeID, err := r.node.GetNodeIDByNS(ns)
+	nodeID, err := r.getNodeIDAllowResource(ns, resType)
 	if err != nil {
-		r.logger.Errorf("getIDByNs fail: %s", err.Error())
 		return err
 	}
 	resOldByte, err := cluster.GetByte(r.cluster, nodeID, resType)
@@ -166,7 +371,95 @@ func (r *resourceMethod) RemoveResource(ns, resType string, resID ...string) err
 	if err != nil {
 		return err
 	}
-	return cluster.SetByte(r.cluster, nodeID, resType, resNewByte)
+	return r.setResourceByte(nodeID, resType, resNewByte)
+}
+
+// RekeyResource change the resource ID from oldID to newID and keep its properties.
+// The resource with the same ID in other leaf node, e.g. machine registered to multiple ns, is rekeyed too.
+// All the change is written in one batch, reject if newID already exist.
+func (r *resourceMethod) RekeyResource(ns, resType, oldID, newID string) error {
+	if oldID == "" || newID == "" || newID == oldID {
+		return common.ErrInvalidParam
+	}
+	n, err := r.node.GetNodeByNS(ns)
+	if err != nil {
+		r.logger.Errorf("GetNodeByNS fail: %s", err.Error())
+		return err
+	}
+	if err := n.CheckResource(resType); err != nil {
+		return err
+	}
+	nodeIDs := []string{n.ID}
+	if n.IsLeaf() {
+		if nodeIDs, err = r.placements(n.ID, resType, oldID); err != nil {
+			return err
+		}
+	}
+
+	rows := []sm.Row{}
+	for _, nodeID := range nodeIDs {
+		rl, err := r.getResourceList(nodeID, resType)
+		if err != nil {
+			return err
+		}
+		index := -1
+		for i := range *rl {
+			switch id, _ := (*rl)[i].ID(); id {
+			case oldID:
+				index = i
+			case newID:
+				r.logger.Errorf("rekey resource fail, id %s already exist in node %s", newID, nodeID)
+				return ErrResourceIDExist
+			}
+		}
+		if index == -1 {
+			if nodeID == n.ID {
+				return ErrNotFound
+			}
+			continue
+		}
+		(*rl)[index].SetProperty(model.IdKey, newID)
+		resByte, err := rl.Marshal()
+		if err != nil {
+			r.logger.Errorf("marshal resource fail when rekey: %s", err.Error())
+			return err
+		}
+		nodeRows, err := r.listRows(nodeID, resType, resByte)
+		if err != nil {
+			return err
+		}
+		rows = append(rows, nodeRows...)
+	}
+
+	for _, nodeID := range nodeIDs {
+		r.cache.invalidate(nodeID, resType)
+	}
+	return r.batch(rows)
+}
+
+// placements return the ID of the leaf nodes which has the resource of resID, begin with nodeID.
+// The resource copied to other ns keep its ID, so the nodes are found by search the ID.
+func (r *resourceMethod) placements(nodeID, resType, resID string) ([]string, error) {
+	search, err := model.NewSearch(false, model.IdKey, resID)
+	if err != nil {
+		return nil, err
+	}
+	result, err := r.SearchResource(node.RootNode, resType, search)
+	if err != nil {
+		r.logger.Errorf("search resource %s fail: %s", resID, err.Error())
+		return nil, err
+	}
+	nodeIDs := []string{nodeID}
+	for ns := range result {
+		id, err := r.node.GetNodeIDByNS(ns)
+		if err != nil {
+			return nil, err
+		}
+		if id != nodeID {
+			nodeIDs = append(nodeIDs, id)
+		}
+	}
+	return nodeIDs, nil
 }
 
 func (r *resourceMethod) CopyResource(fromNs, toNs, resType string, resourceIDs ...string) error {
@@ -222,15 +515,101 @@ func (r *resourceMethod) CopyResource(fromNs, toNs, resType string, resourceIDs
 
 // MoveResource move the resource to a new ns.
 func (r *resourceMethod) MoveResource(oldNs, newNs, resType string, resourceIDs ...string) error {
-	if err := r.CopyResource(oldNs, newNs, resType, resourceIDs...); err != nil {
+	// copy and remove by the buffer and write them in one batch,
+	// so the resources moved inside the ns which has quota is not counted twice.
+	buffer := cluster.NewBuffer(r.cluster)
+	br := &resourceMethod{cluster: buffer, node: r.node, logger: r.logger, cache: newListCache(0)}
+	if err := br.CopyResource(oldNs, newNs, resType, resourceIDs...); err != nil {
 		return err
 	}
-	if err := r.RemoveResource(oldNs, resType, resourceIDs...); err != nil {
+	if err := br.RemoveResource(oldNs, resType, resourceIDs...); err != nil {
 		r.logger.Errorf("DeleteResource resource fail, ns %s, resource type: %s, resourceID: %v, error: %s",
 			newNs, resType, resourceIDs, err.Error())
 		return err
 	}
-	return nil
+	rows := buffer.Rows()
+	for _, row := range rows {
+		r.cache.invalidate(string(row.Bucket), string(row.Key))
+	}
+	return r.batch(rows)
+}
+
+// MoveResourceKeepID move the resources to newNs and keep their ID, return the moved resources.
+// The resource list of both ns is written in one batch.
+func (r *resourceMethod) MoveResourceKeepID(oldNs, newNs, resType string, resourceIDs ...string) ([]model.Resource, error) {
+	if oldNs == newNs || len(resourceIDs) == 0 {
+		return nil, common.ErrInvalidParam
+	}
+	oldNodeID, err := r.getNodeIDAllowResource(oldNs, resType)
+	if err != nil {
+		return nil, err
+	}
+	newNodeID, err := r.getNodeIDAllowResource(newNs, resType)
+	if err != nil {
+		return nil, err
+	}
+	oldList, err := r.getResourceList(oldNodeID, resType)
+	if err != nil {
+		return nil, err
+	}
+	newList, err := r.getResourceList(newNodeID, resType)
+	if err != nil {
+		return nil, err
+	}
+
+	pk := model.PkProperty[resType]
+	newIDs, newPks := map[string]bool{}, map[string]bool{}
+	for _, res := range *newList {
+		id, _ := res.ID()
+		newIDs[id] = true
+		if pkValue, _ := res.ReadProperty(pk); pk != "" && pkValue != "" {
+			newPks[pkValue] = true
+		}
+	}
+	moveIDs := map[string]bool{}
+	for _, id := range resourceIDs {
+		moveIDs[id] = true
+	}
+
+	moved, remain := []model.Resource{}, model.ResourceList{}
+	for _, res := range *oldList {
+		id, _ := res.ID()
+		if !moveIDs[id] {
+			remain = append(remain, res)
+			continue
+		}
+		if newIDs[id] {
+			r.logger.Errorf("move resource fail, id %s already exist in ns %s", id, newNs)
+			return nil, ErrResourceIDExist
+		}
+		if pkValue, _ := res.ReadProperty(pk); newPks[pkValue] {
+			r.logger.Errorf("move resource fail, pk %s already exist in ns %s", pkValue, newNs)
+			return nil, ErrResourcePkExist
+		}
+		moved = append(moved, res)
+	}
+	if len(moved) != len(moveIDs) {
+		return nil, ErrNotFound
+	}
+	newList.AppendResource(moved...)
+
+	rows := []sm.Row{}
+	for nodeID, rl := range map[string]model.ResourceList{oldNodeID: remain, newNodeID: *newList} {
+		resByte := []byte{}
+		if len(rl) != 0 {
+			if resByte, err = rl.Marshal(); err != nil {
+				r.logger.Errorf("marshal resource fail when move: %s", err.Error())
+				return nil, err
+			}
+		}
+		r.cache.invalidate(nodeID, resType)
+		nodeRows, err := r.listRows(nodeID, resType, resByte)
+		if err != nil {
+			return nil, err
+		}
+		rows = append(rows, nodeRows...)
+	}
+	return moved, r.batch(rows)
 }
 
 // SearchResource search the resource.
@@ -1,13 +1,19 @@
 package tree
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"os"
+	"sort"
 	"testing"
 	"time"
 
+	"github.com/lodastack/registry/common"
+	"github.com/lodastack/registry/config"
 	"github.com/lodastack/registry/model"
 	"github.com/lodastack/registry/tree/node"
+	"github.com/lodastack/registry/tree/resource"
 	"github.com/lodastack/registry/tree/test_sample"
 )
 
@@ -103,6 +109,300 @@ func TestSetResourceByNs(t *testing.T) {
 	}
 }
 
+func TestResourceTypes(t *testing.T) {
+	s := test_sample.MustNewStore(t)
+	defer os.RemoveAll(s.Path())
+
+	if err := s.Open(true); err != nil {
+		t.Fatalf("failed to open single-node store: %s", err.Error())
+	}
+	defer s.Close(true)
+	s.WaitForLeader(10 * time.Second)
+	tree, err := NewTree(s)
+	if err != nil {
+		t.Fatalf("new tree fail: %s", err.Error())
+	}
+
+	resource, _ := model.NewResourceList(resMap1)
+	if _, err := tree.NewNode("test", "comment", node.RootNode, node.Leaf); err != nil {
+		t.Fatalf("create leaf behind root fail: %s", err.Error())
+	}
+	if err := tree.SetResource("test."+node.RootNode, "machine", *resource); err != nil {
+		t.Fatalf("set resource fail: %s", err.Error())
+	}
+	if err := tree.AddDashboard("test."+node.RootNode, model.Dashboard{Title: "d0"}); err != nil {
+		t.Fatalf("add dashboard fail: %s", err.Error())
+	}
+
+	resTypes, err := tree.ResourceTypes("test." + node.RootNode)
+	if err != nil || !sort.StringsAreSorted(resTypes) {
+		t.Fatalf("get resource types not match with expect: %v, %v", resTypes, err)
+	}
+	var hasMachine, hasDashboard bool
+	for _, resType := range resTypes {
+		hasMachine = hasMachine || resType == "machine"
+		hasDashboard = hasDashboard || resType == dashboardType
+	}
+	if !hasMachine || !hasDashboard {
+		t.Fatalf("resource types not match with expect: %v", resTypes)
+	}
+
+	if _, err := tree.ResourceTypes("notexist." + node.RootNode); err == nil {
+		t.Fatalf("get resource types of not exist ns success, not match with expect")
+	}
+}
+
+func TestWalkSubtree(t *testing.T) {
+	s := test_sample.MustNewStore(t)
+	defer os.RemoveAll(s.Path())
+
+	if err := s.Open(true); err != nil {
+		t.Fatalf("failed to open single-node store: %s", err.Error())
+	}
+	defer s.Close(true)
+	s.WaitForLeader(10 * time.Second)
+	tree, err := NewTree(s)
+	if err != nil {
+		t.Fatalf("new tree fail: %s", err.Error())
+	}
+
+	resource, _ := model.NewResourceList(resMap1)
+	if _, err := tree.NewNode("walk", "comment", node.RootNode, node.NonLeaf); err != nil {
+		t.Fatalf("create nonleaf fail: %s", err.Error())
+	}
+	for _, name := range []string{"leaf1", "leaf2"} {
+		if _, err := tree.NewNode(name, "comment", "walk."+node.RootNode, node.Leaf); err != nil {
+			t.Fatalf("create leaf fail: %s", err.Error())
+		}
+	}
+	if err := tree.SetResource("leaf1.walk."+node.RootNode, "machine", *resource); err != nil {
+		t.Fatalf("set resource fail: %s", err.Error())
+	}
+
+	visited, machines := []string{}, 0
+	err = tree.WalkSubtree(context.Background(), "walk."+node.RootNode, func(n *node.Node, resources map[string][]model.Resource) error {
+		visited = append(visited, n.Name)
+		if _, ok := resources[dashboardType]; ok {
+			t.Fatalf("dashboard is walked as resource")
+		}
+		machines += len(resources["machine"])
+		return nil
+	})
+	if err != nil || len(visited) != 3 || visited[0] != "walk" || machines != 2 {
+		t.Fatalf("walk subtree not match with expect: %v, %d, %v", visited, machines, err)
+	}
+
+	errStop := errors.New("stop")
+	if err := tree.WalkSubtree(context.Background(), "walk."+node.RootNode, func(n *node.Node, _ map[string][]model.Resource) error {
+		return errStop
+	}); err != errStop {
+		t.Fatalf("walk subtree not stop by visit error: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	visited = []string{}
+	if err := tree.WalkSubtree(ctx, "walk."+node.RootNode, func(n *node.Node, _ map[string][]model.Resource) error {
+		visited = append(visited, n.Name)
+		cancel()
+		return nil
+	}); err != context.Canceled || len(visited) != 1 {
+		t.Fatalf("walk subtree not stop by cancel: %v, %v", visited, err)
+	}
+}
+
+func TestGetResourceProjected(t *testing.T) {
+	s := test_sample.MustNewStore(t)
+	defer os.RemoveAll(s.Path())
+
+	if err := s.Open(true); err != nil {
+		t.Fatalf("failed to open single-node store: %s", err.Error())
+	}
+	defer s.Close(true)
+	s.WaitForLeader(10 * time.Second)
+	tree, _ := NewTree(s)
+
+	ns := "testProject." + node.RootNode
+	if _, err := tree.NewNode("testProject", "comment", node.RootNode, node.Leaf); err != nil {
+		t.Fatalf("create leaf fail: %s", err.Error())
+	}
+	machine1 := model.NewResource(map[string]string{"hostname": "host1", "ip": "10.0.0.1", "sn": "sn1"})
+	machine2 := model.NewResource(map[string]string{"hostname": "host2", "sn": "sn2"})
+	id1 := machine1.InitID()
+	if err := tree.SetResource(ns, "machine", model.ResourceList{machine1, machine2}); err != nil {
+		t.Fatalf("set resource fail: %s", err.Error())
+	}
+
+	// case 1: all resources, the missing field is not returned.
+	rs, err := tree.GetResourceProjected(ns, "machine", []string{"hostname", "ip"})
+	if err != nil || len(rs) != 2 {
+		t.Fatalf("GetResourceProjected not match with expect: %+v, error: %v", rs, err)
+	}
+	for _, r := range rs {
+		if _, ok := r["sn"]; ok || r["hostname"] == "" {
+			t.Fatalf("GetResourceProjected not match with expect: %+v", rs)
+		}
+		if _, ok := r["ip"]; ok != (r["hostname"] == "host1") {
+			t.Fatalf("GetResourceProjected not match with expect: %+v", rs)
+		}
+	}
+
+	// case 2: by resource ID.
+	rs, err = tree.GetResourceProjected(ns, "machine", []string{"sn"}, id1)
+	if err != nil || len(rs) != 1 || len(rs[0]) != 1 || rs[0]["sn"] != "sn1" {
+		t.Fatalf("GetResourceProjected by ID not match with expect: %+v, error: %v", rs, err)
+	}
+}
+
+func TestGetResourceByIndex(t *testing.T) {
+	model.RegisterIndex("machine", "sn")
+	defer delete(model.IndexProperty, "machine")
+
+	s := test_sample.MustNewStore(t)
+	defer os.RemoveAll(s.Path())
+
+	if err := s.Open(true); err != nil {
+		t.Fatalf("failed to open single-node store: %s", err.Error())
+	}
+	defer s.Close(true)
+	s.WaitForLeader(10 * time.Second)
+	tree, err := NewTree(s)
+	if err != nil {
+		t.Fatalf("new tree fail: %s", err.Error())
+	}
+	for _, name := range []string{"index1", "index2"} {
+		if _, err := tree.NewNode(name, "comment", node.RootNode, node.Leaf); err != nil {
+			t.Fatalf("create leaf fail: %s", err.Error())
+		}
+	}
+	ns1, ns2 := "index1."+node.RootNode, "index2."+node.RootNode
+
+	rl, _ := model.NewResourceList([]map[string]string{
+		{"hostname": "host1", "sn": "sn1"},
+		{"hostname": "host2", "sn": "sn2"},
+	})
+	if err := tree.SetResource(ns1, "machine", *rl); err != nil {
+		t.Fatalf("set resource fail: %s", err.Error())
+	}
+	rs, err := tree.GetResourceByIndex(ns1, "machine", "sn", "sn1")
+	if err != nil || len(rs) != 1 || rs[0]["hostname"] != "host1" {
+		t.Fatalf("get resource by index not match with expect: %v, %v", rs, err)
+	}
+	id1, _ := rs[0].ID()
+
+	if err := tree.UpdateResource(ns1, "machine", id1, map[string]string{"sn": "sn3"}); err != nil {
+		t.Fatalf("update resource fail: %s", err.Error())
+	}
+	if rs, err := tree.GetResourceByIndex(ns1, "machine", "sn", "sn1"); err != nil || len(rs) != 0 {
+		t.Fatalf("get resource by old index value not match with expect: %v, %v", rs, err)
+	}
+	if rs, err := tree.GetResourceByIndex(ns1, "machine", "sn", "sn3"); err != nil || len(rs) != 1 {
+		t.Fatalf("get resource by updated index value not match with expect: %v, %v", rs, err)
+	}
+
+	if _, err := tree.MoveResourceWithRefs(ns1, ns2, "machine", id1); err != nil {
+		t.Fatalf("move resource fail: %s", err.Error())
+	}
+	if rs, err := tree.GetResourceByIndex(ns1, "machine", "sn", "sn3"); err != nil || len(rs) != 0 {
+		t.Fatalf("get moved resource by index in old ns not match with expect: %v, %v", rs, err)
+	}
+	if rs, err := tree.GetResourceByIndex(ns2, "machine", "sn", "sn3"); err != nil || len(rs) != 1 {
+		t.Fatalf("get moved resource by index in new ns not match with expect: %v, %v", rs, err)
+	}
+
+	// property without index is searched in the resource list.
+	if rs, err := tree.GetResourceByIndex(ns1, "machine", "hostname", "host2"); err != nil || len(rs) != 1 {
+		t.Fatalf("get resource by property without index not match with expect: %v, %v", rs, err)
+	}
+
+	// the index is not used and removed after the property is not registered.
+	delete(model.IndexProperty, "machine")
+	if err := tree.UpdateResource(ns2, "machine", id1, map[string]string{"sn": "sn4"}); err != nil {
+		t.Fatalf("update resource fail: %s", err.Error())
+	}
+	model.RegisterIndex("machine", "sn")
+	if rs, err := tree.GetResourceByIndex(ns2, "machine", "sn", "sn4"); err != nil || len(rs) != 1 {
+		t.Fatalf("get resource by index registered again not match with expect: %v, %v", rs, err)
+	}
+	if rs, err := tree.GetResourceByIndex(ns2, "machine", "sn", "sn3"); err != nil || len(rs) != 0 {
+		t.Fatalf("get resource by stale index not match with expect: %v, %v", rs, err)
+	}
+
+	// the index is removed with the whole resource list and with the node.
+	model.RegisterIndex("collect", "name")
+	defer delete(model.IndexProperty, "collect")
+	collects, _ := model.NewResourceList([]map[string]string{{"name": "collect1"}})
+	if err := tree.SetResource(ns2, "collect", *collects); err != nil {
+		t.Fatalf("set resource fail: %s", err.Error())
+	}
+	n2, err := tree.GetNodeByNS(ns2)
+	if err != nil {
+		t.Fatalf("get node fail: %s", err.Error())
+	}
+	if err := tree.RemoveResource(ns2, "machine", id1); err != nil {
+		t.Fatalf("remove resource fail: %s", err.Error())
+	}
+	if v, err := s.View([]byte(resource.IndexBucket), []byte(n2.ID+"/machine/sn")); err != nil || len(v) != 0 {
+		t.Fatalf("index of removed resource list is not removed: %s, %v", v, err)
+	}
+	if err := tree.RemoveNode(ns2); err != nil {
+		t.Fatalf("remove node fail: %s", err.Error())
+	}
+	index, err := s.ViewPrefix([]byte(resource.IndexBucket), []byte(n2.ID+"/"))
+	if err != nil {
+		t.Fatalf("view index fail: %s", err.Error())
+	}
+	for k, v := range index {
+		if len(v) != 0 {
+			t.Fatalf("index %s of removed node is not removed: %s", k, v)
+		}
+	}
+}
+
+func TestWalkResource(t *testing.T) {
+	s := test_sample.MustNewStore(t)
+	defer os.RemoveAll(s.Path())
+
+	if err := s.Open(true); err != nil {
+		t.Fatalf("failed to open single-node store: %s", err.Error())
+	}
+	defer s.Close(true)
+	s.WaitForLeader(10 * time.Second)
+	tree, err := NewTree(s)
+	if err != nil {
+		t.Fatalf("new tree fail: %s", err.Error())
+	}
+	if _, err := tree.NewNode("walk", "comment", node.RootNode, node.NonLeaf); err != nil {
+		t.Fatalf("create nonleaf fail: %s", err.Error())
+	}
+	parentNs := "walk." + node.RootNode
+	for _, name := range []string{"walk1", "walk2"} {
+		if _, err := tree.NewNode(name, "comment", parentNs, node.Leaf); err != nil {
+			t.Fatalf("create leaf fail: %s", err.Error())
+		}
+		rl, _ := model.NewResourceList([]map[string]string{{"hostname": name + "-1"}, {"hostname": name + "-2"}})
+		if err := tree.SetResource(name+"."+parentNs, "machine", *rl); err != nil {
+			t.Fatalf("set resource fail: %s", err.Error())
+		}
+	}
+
+	walked := []string{}
+	if err := tree.WalkResource(parentNs, "machine", func(r model.Resource) error {
+		hostname, _ := r.ReadProperty("hostname")
+		walked = append(walked, hostname)
+		return nil
+	}); err != nil || len(walked) != 4 {
+		t.Fatalf("walk resource of nonleaf not match with expect: %v, %v", walked, err)
+	}
+	walked = walked[:0]
+	if err := tree.WalkResource("walk1."+parentNs, "machine", func(r model.Resource) error {
+		hostname, _ := r.ReadProperty("hostname")
+		walked = append(walked, hostname)
+		return nil
+	}); err != nil || len(walked) != 2 || walked[0] != "walk1-1" {
+		t.Fatalf("walk resource of leaf not match with expect: %v, %v", walked, err)
+	}
+}
+
 func TestSearchResource(t *testing.T) {
 	s := test_sample.MustNewStore(t)
 	defer os.RemoveAll(s.Path())
@@ -268,6 +568,125 @@ func TestGetResAfterSetOtherNs(t *testing.T) {
 	}
 }
 
+func TestRekeyResource(t *testing.T) {
+	s := test_sample.MustNewStore(t)
+	defer os.RemoveAll(s.Path())
+
+	if err := s.Open(true); err != nil {
+		t.Fatalf("failed to open single-node store: %s", err.Error())
+	}
+	defer s.Close(true)
+	s.WaitForLeader(10 * time.Second)
+	tree, _ := NewTree(s)
+
+	machine1 := model.NewResource(map[string]string{"hostname": "host1", "sn": "sn1"})
+	machine2 := model.NewResource(map[string]string{"hostname": "host2"})
+	oldID, otherID, newID := machine1.InitID(), machine2.InitID(), common.GenUUID()
+
+	for _, name := range []string{"testRekey1", "testRekey2"} {
+		if _, err := tree.NewNode(name, "comment", node.RootNode, node.Leaf); err != nil {
+			t.Fatalf("create %s fail: %s", name, err.Error())
+		}
+		if err := tree.AppendResource(name+"."+node.RootNode, "machine", machine1, machine2); err != nil {
+			t.Fatalf("append machine to %s fail: %s", name, err.Error())
+		}
+	}
+
+	if err := tree.RekeyResource("testRekey1.loda", "machine", oldID, otherID); err == nil {
+		t.Fatalf("rekey to exist id success, not match with expect")
+	}
+	if err := tree.RekeyResource("testRekey1.loda", "machine", oldID, newID); err != nil {
+		t.Fatalf("rekey resource fail: %s", err.Error())
+	}
+	for _, ns := range []string{"testRekey1.loda", "testRekey2.loda"} {
+		if rs, err := tree.GetResource(ns, "machine", oldID); err != nil || len(rs) != 0 {
+			t.Fatalf("old id still exist in %s: %+v, %v", ns, rs, err)
+		}
+		rs, err := tree.GetResource(ns, "machine", newID)
+		if err != nil || len(rs) != 1 || rs[0]["sn"] != "sn1" || rs[0]["hostname"] != "host1" {
+			t.Fatalf("resource of new id in %s not match with expect: %+v, %v", ns, rs, err)
+		}
+	}
+	// the id in the node not has the resource is not conflict.
+	machine3 := model.NewResource(map[string]string{"hostname": "host3"})
+	unrelatedID := machine3.InitID()
+	if _, err := tree.NewNode("testRekey3", "comment", node.RootNode, node.Leaf); err != nil {
+		t.Fatalf("create testRekey3 fail: %s", err.Error())
+	}
+	if err := tree.AppendResource("testRekey3."+node.RootNode, "machine", machine3); err != nil {
+		t.Fatalf("append machine to testRekey3 fail: %s", err.Error())
+	}
+	if err := tree.RekeyResource("testRekey1.loda", "machine", otherID, unrelatedID); err != nil {
+		t.Fatalf("rekey to id of unrelated node fail: %s", err.Error())
+	}
+	if rs, err := tree.GetResource("testRekey2.loda", "machine", unrelatedID); err != nil || len(rs) != 1 || rs[0]["hostname"] != "host2" {
+		t.Fatalf("resource of unrelated id in testRekey2 not match with expect: %+v, %v", rs, err)
+	}
+	if err := tree.RekeyResource("testRekey1.loda", "machine", oldID, common.GenUUID()); err == nil {
+		t.Fatalf("rekey not exist id success, not match with expect")
+	}
+}
+
+func TestMoveResourceWithRefs(t *testing.T) {
+	s := test_sample.MustNewStore(t)
+	defer os.RemoveAll(s.Path())
+
+	if err := s.Open(true); err != nil {
+		t.Fatalf("failed to open single-node store: %s", err.Error())
+	}
+	defer s.Close(true)
+	s.WaitForLeader(10 * time.Second)
+	tree, _ := NewTree(s)
+
+	machine1 := model.NewResource(map[string]string{"hostname": "host1"})
+	machine2 := model.NewResource(map[string]string{"hostname": "host2"})
+	id1, id2 := machine1.InitID(), machine2.InitID()
+	for _, name := range []string{"testMoveRef1", "testMoveRef2"} {
+		if _, err := tree.NewNode(name, "comment", node.RootNode, node.Leaf); err != nil {
+			t.Fatalf("create %s fail: %s", name, err.Error())
+		}
+	}
+	if err := tree.AppendResource("testMoveRef1.loda", "machine", machine1, machine2); err != nil {
+		t.Fatalf("append machine fail: %s", err.Error())
+	}
+	if err := tree.AddDashboard("testMoveRef2.loda", model.Dashboard{Title: "d0", Panels: []model.Panel{
+		{Title: "p0", Targets: []model.Target{
+			{Ns: "testMoveRef1.loda", Measurement: "cpu.idle", Where: "host = 'host2'"},
+			{Ns: "testMoveRef1.loda", Measurement: "cpu.idle", Where: "host = 'host1'"},
+			{Ns: "testMoveRef1.loda", Measurement: "cpu.idle", Where: "host = 'host10'"},
+		}},
+	}}); err != nil {
+		t.Fatalf("add dashboard fail: %s", err.Error())
+	}
+
+	refs, err := tree.MoveResourceWithRefs("testMoveRef1.loda", "testMoveRef2.loda", "machine", id1)
+	if err != nil {
+		t.Fatalf("move resource fail: %s", err.Error())
+	}
+	if len(refs) != 1 || refs[0].NS != "testMoveRef2.loda" || refs[0].Target != 1 {
+		t.Fatalf("dangling refs not match with expect: %+v", refs)
+	}
+	if rs, err := tree.GetResource("testMoveRef2.loda", "machine", id1); err != nil || len(rs) != 1 || rs[0]["hostname"] != "host1" {
+		t.Fatalf("moved resource not match with expect: %+v, %v", rs, err)
+	}
+	if rs, err := tree.GetResourceList("testMoveRef1.loda", "machine"); err != nil || len(*rs) != 1 || (*rs)[0]["hostname"] != "host2" {
+		t.Fatalf("resource of old ns not match with expect: %+v, %v", rs, err)
+	}
+
+	// move fail because the pk already exist in new ns.
+	if err := tree.AppendResource("testMoveRef2.loda", "machine", model.NewResource(map[string]string{"hostname": "host2"})); err != nil {
+		t.Fatalf("append machine fail: %s", err.Error())
+	}
+	if _, err := tree.MoveResourceWithRefs("testMoveRef1.loda", "testMoveRef2.loda", "machine", id2); err != resource.ErrResourcePkExist {
+		t.Fatalf("move resource with exist pk not match with expect: %v", err)
+	}
+
+	// move again fail because the resource is not in old ns.
+	if _, err := tree.MoveResourceWithRefs("testMoveRef1.loda", "testMoveRef2.loda", "machine", id1); err == nil {
+		t.Fatalf("move not exist resource success, not match with expect")
+	}
+}
+
 func TestMoveResource(t *testing.T) {
 	s := test_sample.MustNewStore(t)
 	defer os.RemoveAll(s.Path())
@@ -473,3 +892,302 @@ func TestCopyResource(t *testing.T) {
 		t.Fatalf("copy reource success, not match with expect")
 	}
 }
+
+func TestSoftRemoveResource(t *testing.T) {
+	s := test_sample.MustNewStore(t)
+	defer os.RemoveAll(s.Path())
+
+	if err := s.Open(true); err != nil {
+		t.Fatalf("failed to open single-node store: %s", err.Error())
+	}
+	defer s.Close(true)
+	s.WaitForLeader(10 * time.Second)
+	tree, _ := NewTree(s)
+
+	if _, err := tree.NewNode("testSoftRemove", "comment", node.RootNode, node.Leaf); err != nil {
+		t.Fatalf("create leaf fail: %s", err.Error())
+	}
+	ns := "testSoftRemove." + node.RootNode
+	machine1 := model.NewResource(map[string]string{"hostname": "host1"})
+	machine2 := model.NewResource(map[string]string{"hostname": "host2"})
+	if err := tree.AppendResource(ns, "machine", machine1, machine2); err != nil {
+		t.Fatalf("AppendResource fail: %s", err.Error())
+	}
+	rl, err := tree.GetResourceList(ns, "machine")
+	if err != nil || len(*rl) != 2 {
+		t.Fatalf("GetResourceList not match with expect: %+v, error: %v", rl, err)
+	}
+	id1, _ := (*rl)[0].ID()
+	id2, _ := (*rl)[1].ID()
+
+	// case 1: soft remove resource, the resource is hidden and kept as tombstone.
+	if err := tree.SoftRemoveResource(ns, "machine", id1); err != nil {
+		t.Fatalf("SoftRemoveResource fail: %s", err.Error())
+	}
+	if rl, err := tree.GetResourceList(ns, "machine"); err != nil || len(*rl) != 1 {
+		t.Fatalf("GetResourceList after soft remove not match with expect: %+v, error: %v", rl, err)
+	}
+	deleted, err := tree.GetDeletedResources(ns, "machine")
+	if err != nil || len(deleted) != 1 {
+		t.Fatalf("GetDeletedResources not match with expect: %+v, error: %v", deleted, err)
+	}
+	if id, _ := deleted[0].ID(); id != id1 {
+		t.Fatalf("tombstone ID not match with expect: %s", id)
+	}
+	if deleteTime, _ := deleted[0].ReadProperty(DeleteTimeProp); deleteTime == "" {
+		t.Fatalf("tombstone has no delete time: %+v", deleted[0])
+	}
+
+	// case 2: soft remove not exist resource.
+	if err := tree.SoftRemoveResource(ns, "machine", "notexist"); err == nil {
+		t.Fatalf("SoftRemoveResource not exist resource not match with expect")
+	}
+
+	// case 3: restore the resource with its ID.
+	if err := tree.RestoreResource(ns, "machine", id1); err != nil {
+		t.Fatalf("RestoreResource fail: %s", err.Error())
+	}
+	if res, err := tree.GetResource(ns, "machine", id1); err != nil || len(res) != 1 {
+		t.Fatalf("GetResource after restore not match with expect: %+v, error: %v", res, err)
+	} else if deleteTime, _ := res[0].ReadProperty(DeleteTimeProp); deleteTime != "" {
+		t.Fatalf("restored resource has delete time: %+v", res[0])
+	}
+	if deleted, err := tree.GetDeletedResources(ns, "machine"); err != nil || len(deleted) != 0 {
+		t.Fatalf("GetDeletedResources after restore not match with expect: %+v, error: %v", deleted, err)
+	}
+	if err := tree.RestoreResource(ns, "machine", id1); err == nil {
+		t.Fatalf("RestoreResource twice not match with expect")
+	}
+
+	// case 4: purge the expired tombstone.
+	if err := tree.SoftRemoveResource(ns, "machine", id2); err != nil {
+		t.Fatalf("SoftRemoveResource fail: %s", err.Error())
+	}
+	if purged, err := tree.PurgeTombstones(time.Now().Add(-time.Hour)); err != nil || purged != 0 {
+		t.Fatalf("PurgeTombstones not match with expect: %d, error: %v", purged, err)
+	}
+	if purged, err := tree.PurgeTombstones(time.Now().Add(time.Hour)); err != nil || purged != 1 {
+		t.Fatalf("PurgeTombstones not match with expect: %d, error: %v", purged, err)
+	}
+	if deleted, err := tree.GetDeletedResources(ns, "machine"); err != nil || len(deleted) != 0 {
+		t.Fatalf("GetDeletedResources after purge not match with expect: %+v, error: %v", deleted, err)
+	}
+
+	// case 5: the tombstone is kept when the node is renamed, and returned with the resources if include deleted.
+	if err := tree.SoftRemoveResource(ns, "machine", id1); err != nil {
+		t.Fatalf("SoftRemoveResource fail: %s", err.Error())
+	}
+	if err := tree.UpdateNode(ns, "testSoftRenamed", "comment", ""); err != nil {
+		t.Fatalf("UpdateNode fail: %s", err.Error())
+	}
+	ns = "testSoftRenamed." + node.RootNode
+	if all, err := tree.GetResourceIncludeDeleted(ns, "machine"); err != nil || len(all) != 1 {
+		t.Fatalf("GetResourceIncludeDeleted after rename not match with expect: %+v, error: %v", all, err)
+	}
+	if all, err := tree.GetResourceIncludeDeleted(ns, "machine", id1); err != nil || len(all) != 1 {
+		t.Fatalf("GetResourceIncludeDeleted by ID not match with expect: %+v, error: %v", all, err)
+	}
+	if all, err := tree.GetResourceIncludeDeleted(node.RootNode, "machine"); err != nil || len(all) != 1 {
+		t.Fatalf("GetResourceIncludeDeleted of nonleaf not match with expect: %+v, error: %v", all, err)
+	}
+	if err := tree.RestoreResource(ns, "machine", id1); err != nil {
+		t.Fatalf("RestoreResource after rename fail: %s", err.Error())
+	}
+}
+
+func TestRunPurge(t *testing.T) {
+	s := test_sample.MustNewStore(t)
+	defer os.RemoveAll(s.Path())
+
+	if err := s.Open(true); err != nil {
+		t.Fatalf("failed to open single-node store: %s", err.Error())
+	}
+	defer s.Close(true)
+	s.WaitForLeader(10 * time.Second)
+	tree, _ := NewTree(s)
+	if _, err := tree.NewNode("testPurge", "comment", node.RootNode, node.Leaf); err != nil {
+		t.Fatalf("create leaf fail: %s", err.Error())
+	}
+	ns := "testPurge." + node.RootNode
+	n, _ := tree.GetNodeByNS(ns)
+
+	// the tombstone soft removed long ago.
+	expired := model.NewResource(map[string]string{"hostname": "host1", DeleteTimeProp: "1"})
+	id := expired.InitID()
+	resByte, _ := (&model.ResourceList{expired}).Marshal()
+	if err := s.Update([]byte(tombstoneBucket), []byte(tombstoneKey(n.ID, "machine", id)), resByte); err != nil {
+		t.Fatalf("write tombstone fail: %s", err.Error())
+	}
+
+	retention, interval := config.C.CommonConf.TombstoneRetention, purgeInterval
+	config.C.CommonConf.TombstoneRetention, purgeInterval = 1, 10*time.Millisecond
+	defer func() { config.C.CommonConf.TombstoneRetention, purgeInterval = retention, interval }()
+
+	// not purged by the follower.
+	stop := tree.RunPurge(func() bool { return false })
+	time.Sleep(50 * time.Millisecond)
+	stop()
+	stop()
+	if deleted, err := tree.GetDeletedResources(ns, "machine"); err != nil || len(deleted) != 1 {
+		t.Fatalf("tombstone purged by follower: %+v, error: %v", deleted, err)
+	}
+
+	// purged by the leader.
+	stop = tree.RunPurge(func() bool { return true })
+	defer stop()
+	for i := 0; i < 100; i++ {
+		if deleted, err := tree.GetDeletedResources(ns, "machine"); err == nil && len(deleted) == 0 {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("tombstone not purged by leader")
+}
+
+func TestValidateResources(t *testing.T) {
+	s := test_sample.MustNewStore(t)
+	defer os.RemoveAll(s.Path())
+
+	if err := s.Open(true); err != nil {
+		t.Fatalf("failed to open single-node store: %s", err.Error())
+	}
+	defer s.Close(true)
+	s.WaitForLeader(10 * time.Second)
+	tree, _ := NewTree(s)
+
+	if _, err := tree.NewNode("testValidate", "comment", node.RootNode, node.Leaf); err != nil {
+		t.Fatalf("create leaf fail: %s", err.Error())
+	}
+	ns := "testValidate." + node.RootNode
+	exist := model.NewResource(map[string]string{"hostname": "host1"})
+	if err := tree.AppendResource(ns, "machine", exist); err != nil {
+		t.Fatalf("AppendResource fail: %s", err.Error())
+	}
+	existID, _ := exist.ID()
+
+	// case 1: valid resources.
+	if errs, err := tree.ValidateResources(ns, "machine",
+		model.Resource{"hostname": "host2"}, model.Resource{"hostname": "host3"}); err != nil || len(errs) != 0 {
+		t.Fatalf("ValidateResources not match with expect: %v, error: %v", errs, err)
+	}
+
+	// case 2: all problems are returned with the index.
+	errs, err := tree.ValidateResources(ns, "machine",
+		model.Resource{"hostname": "host1"},
+		model.Resource{"ip": "127.0.0.1"},
+		model.Resource{"hostname": "host2"},
+		model.Resource{"hostname": "host2"},
+		model.Resource{model.IdKey: existID, "hostname": "host4"})
+	if err != nil {
+		t.Fatalf("ValidateResources fail: %s", err.Error())
+	}
+	indexes := []int{}
+	for _, e := range errs {
+		indexes = append(indexes, e.Index)
+	}
+	if len(indexes) != 4 || indexes[0] != 0 || indexes[1] != 1 || indexes[2] != 3 || indexes[3] != 4 {
+		t.Fatalf("ValidateResources not match with expect: %v", errs)
+	}
+	if rl, err := tree.GetResourceList(ns, "machine"); err != nil || len(*rl) != 1 {
+		t.Fatalf("ValidateResources should not write resource: %+v, error: %v", rl, err)
+	}
+}
+
+func TestCountResources(t *testing.T) {
+	s := test_sample.MustNewStore(t)
+	defer os.RemoveAll(s.Path())
+
+	if err := s.Open(true); err != nil {
+		t.Fatalf("failed to open single-node store: %s", err.Error())
+	}
+	defer s.Close(true)
+	s.WaitForLeader(10 * time.Second)
+	tree, _ := NewTree(s)
+
+	if _, err := tree.NewNode("testCount", "comment", node.RootNode, node.NonLeaf); err != nil {
+		t.Fatalf("create nonleaf fail: %s", err.Error())
+	}
+	parentNs := "testCount." + node.RootNode
+	for _, name := range []string{"leaf1", "leaf2"} {
+		if _, err := tree.NewNode(name, "comment", parentNs, node.Leaf); err != nil {
+			t.Fatalf("create leaf fail: %s", err.Error())
+		}
+	}
+	base, err := tree.CountResources(context.Background(), parentNs)
+	if err != nil {
+		t.Fatalf("CountResources fail: %s", err.Error())
+	}
+	if err := tree.AppendResource("leaf1."+parentNs, "machine",
+		model.NewResource(map[string]string{"hostname": "host1"}),
+		model.NewResource(map[string]string{"hostname": "host2"})); err != nil {
+		t.Fatalf("AppendResource fail: %s", err.Error())
+	}
+	if err := tree.AppendResource("leaf2."+parentNs, "machine",
+		model.NewResource(map[string]string{"hostname": "host3"})); err != nil {
+		t.Fatalf("AppendResource fail: %s", err.Error())
+	}
+
+	// case 1: count the resources of the subtree.
+	counts, err := tree.CountResources(context.Background(), parentNs)
+	if err != nil || counts["machine"] != base["machine"]+3 {
+		t.Fatalf("CountResources not match with expect: %v, base: %v, error: %v", counts, base, err)
+	}
+	if counts, err := tree.CountResources(context.Background(), "leaf2."+parentNs); err != nil || counts["machine"] != 1 {
+		t.Fatalf("CountResources of leaf not match with expect: %v, error: %v", counts, err)
+	}
+
+	// case 2: not exist ns.
+	if _, err := tree.CountResources(context.Background(), "notexist."+node.RootNode); err != common.ErrNodeNotFound {
+		t.Fatalf("CountResources of not exist ns not match with expect, error: %v", err)
+	}
+
+	// case 3: ctx is canceled.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := tree.CountResources(ctx, parentNs); err != context.Canceled {
+		t.Fatalf("CountResources with canceled ctx not match with expect, error: %v", err)
+	}
+}
+
+func TestTouchResource(t *testing.T) {
+	s := test_sample.MustNewStore(t)
+	defer os.RemoveAll(s.Path())
+
+	if err := s.Open(true); err != nil {
+		t.Fatalf("failed to open single-node store: %s", err.Error())
+	}
+	defer s.Close(true)
+	s.WaitForLeader(10 * time.Second)
+	tree, _ := NewTree(s)
+
+	if _, err := tree.NewNode("testTouch", "comment", node.RootNode, node.Leaf); err != nil {
+		t.Fatalf("create leaf fail: %s", err.Error())
+	}
+	ns := "testTouch." + node.RootNode
+	machine := model.NewResource(map[string]string{"hostname": "host1", "ip": "127.0.0.1"})
+	if err := tree.AppendResource(ns, "machine", machine); err != nil {
+		t.Fatalf("AppendResource fail: %s", err.Error())
+	}
+	id, _ := machine.ID()
+
+	// case 1: touch the resource, the resource itself is not changed.
+	if touchTime, err := tree.TouchTime(ns, "machine", id); err != nil || !touchTime.IsZero() {
+		t.Fatalf("touch time before touch not match with expect: %v, error: %v", touchTime, err)
+	}
+	nodeID, _ := tree.getNodeIDByNS(ns)
+	before, _ := tree.getByteFromStore(nodeID, "machine")
+	if err := tree.TouchResource(ns, "machine", id); err != nil {
+		t.Fatalf("TouchResource fail: %s", err.Error())
+	}
+	if touchTime, err := tree.TouchTime(ns, "machine", id); err != nil || time.Since(touchTime) > time.Minute {
+		t.Fatalf("touch time not match with expect: %v, error: %v", touchTime, err)
+	}
+	if after, _ := tree.getByteFromStore(nodeID, "machine"); string(after) != string(before) {
+		t.Fatalf("touched resource is rewritten: %s", string(after))
+	}
+
+	// case 2: touch not exist resource.
+	if err := tree.TouchResource(ns, "machine", "notexist"); err == nil {
+		t.Fatalf("TouchResource not exist resource not match with expect")
+	}
+}
@@ -0,0 +1,184 @@
+package tree
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/lodastack/registry/common"
+	"github.com/lodastack/registry/model"
+	"github.com/lodastack/registry/tree/resource"
+)
+
+// tombstoneBucket save the soft removed resource, the key is nodeID/resType/resID,
+// so the tombstone is kept when the node is renamed or moved, and every tombstone is written
+// and purged by its own key without rewriting the others.
+const tombstoneBucket = "tombstone"
+
+// DeleteTimeProp is the unix time the resource is soft removed.
+const DeleteTimeProp = "deletetime"
+
+func (t *Tree) initTombstoneBucket() error {
+	if err := t.cluster.CreateBucketIfNotExist([]byte(tombstoneBucket)); err != nil {
+		t.logger.Errorf("tree init %s CreateBucketIfNotExist fail: %s", tombstoneBucket, err.Error())
+		return err
+	}
+	return nil
+}
+
+// tombstonePrefix return the key prefix of the tombstones of the node/resType.
+func tombstonePrefix(nodeID, resType string) string {
+	return nodeID + "/" + resType + "/"
+}
+
+func tombstoneKey(nodeID, resType, resID string) string {
+	return tombstonePrefix(nodeID, resType) + resID
+}
+
+// SoftRemoveResource remove the resources from the ns and keep them as tombstone with the delete time,
+// the resources could be restored by RestoreResource before purged.
+// The resources are removed and kept in one batch.
+func (t *Tree) SoftRemoveResource(ns, resType string, resIDs ...string) error {
+	if len(resIDs) == 0 {
+		return resource.ErrNotFound
+	}
+	now := strconv.FormatInt(time.Now().Unix(), 10)
+	t.retainMu.Lock()
+	defer t.retainMu.Unlock()
+	return t.tx(func(tx *treeTx) error {
+		removed, err := tx.GetResource(ns, resType, resIDs...)
+		if err != nil {
+			return err
+		}
+		if len(removed) != len(resIDs) {
+			t.logger.Errorf("soft remove %s %v of ns %s fail: some resource not found", resType, resIDs, ns)
+			return resource.ErrNotFound
+		}
+		if err := tx.RemoveResource(ns, resType, resIDs...); err != nil {
+			return err
+		}
+
+		nodeID, err := t.getNodeIDByNS(ns)
+		if err != nil {
+			return err
+		}
+		for _, r := range removed {
+			id, _ := r.ID()
+			r.SetProperty(DeleteTimeProp, now)
+			resByte, err := (&model.ResourceList{r}).Marshal()
+			if err != nil {
+				return err
+			}
+			if err := tx.buffer.Update([]byte(tombstoneBucket), []byte(tombstoneKey(nodeID, resType, id)), resByte); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// RestoreResource restore the soft removed resources to the ns with their ID.
+// The quota is checked when the resources are appended in the transaction.
+func (t *Tree) RestoreResource(ns, resType string, resIDs ...string) error {
+	if len(resIDs) == 0 {
+		return resource.ErrNotFound
+	}
+	t.retainMu.Lock()
+	defer t.retainMu.Unlock()
+	return t.tx(func(tx *treeTx) error {
+		nodeID, err := t.getNodeIDByNS(ns)
+		if err != nil {
+			return err
+		}
+		restored := make([]model.Resource, 0, len(resIDs))
+		for _, id := range resIDs {
+			key := tombstoneKey(nodeID, resType, id)
+			tombstones, err := readResourceList(tx.buffer, tombstoneBucket, key)
+			if err != nil {
+				t.logger.Errorf("read tombstone of %s fail: %s", key, err.Error())
+				return err
+			}
+			if len(tombstones) == 0 {
+				t.logger.Errorf("restore %s %v of ns %s fail: tombstone %s not found", resType, resIDs, ns, id)
+				return resource.ErrNotFound
+			}
+			delete(tombstones[0], DeleteTimeProp)
+			restored = append(restored, tombstones[0])
+			if err := tx.buffer.Update([]byte(tombstoneBucket), []byte(key), []byte{}); err != nil {
+				return err
+			}
+		}
+		return tx.AppendResource(ns, resType, restored...)
+	})
+}
+
+// GetDeletedResources return the soft removed resources of the ns which are not purged yet.
+// If the node could not have the resource type, return the soft removed resources of all its leaf child node.
+func (t *Tree) GetDeletedResources(ns, resType string) (model.ResourceList, error) {
+	n, err := t.node.GetNodeByNS(ns)
+	if err != nil {
+		t.logger.Errorf("GetNodeByNS fail: %s", err.Error())
+		return nil, err
+	}
+	nodeIDs := []string{n.ID}
+	if !n.AllowResource(resType) {
+		if nodeIDs, err = n.LeafChildIDs(); err != nil && err != common.ErrNoLeafChild {
+			return nil, err
+		}
+	}
+	result := model.ResourceList{}
+	for _, nodeID := range nodeIDs {
+		tombstones, err := readResourcesByPrefix(t.cluster, tombstoneBucket, tombstonePrefix(nodeID, resType))
+		if err != nil {
+			t.logger.Errorf("read tombstone of %s %s fail: %s", ns, resType, err.Error())
+			return nil, err
+		}
+		result.AppendResources(tombstones)
+	}
+	return result, nil
+}
+
+// GetResourceIncludeDeleted return the resources of the ns like GetResource,
+// and the soft removed resources not purged yet with the delete time.
+// Return all resources of the type if no resID is provided.
+func (t *Tree) GetResourceIncludeDeleted(ns, resType string, resID ...string) ([]model.Resource, error) {
+	result := []model.Resource{}
+	if len(resID) == 0 {
+		rl, err := t.resource.GetResourceList(ns, resType)
+		if err != nil {
+			return nil, err
+		}
+		if rl != nil {
+			result = append(result, *rl...)
+		}
+	} else {
+		resources, err := t.resource.GetResource(ns, resType, resID...)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, resources...)
+	}
+
+	deleted, err := t.GetDeletedResources(ns, resType)
+	if err != nil {
+		return nil, err
+	}
+	if len(resID) == 0 {
+		return append(result, deleted...), nil
+	}
+	want := make(map[string]bool, len(resID))
+	for _, id := range resID {
+		want[id] = true
+	}
+	for _, r := range deleted {
+		if id, _ := r.ID(); want[id] {
+			result = append(result, r)
+		}
+	}
+	return result, nil
+}
+
+// PurgeTombstones remove the tombstone soft removed before the time,
+// return the number of resource purged.
+func (t *Tree) PurgeTombstones(before time.Time) (int, error) {
+	return t.purgeExpired(tombstoneBucket, DeleteTimeProp, before)
+}
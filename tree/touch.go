@@ -0,0 +1,83 @@
+package tree
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/lodastack/registry/model"
+	"github.com/lodastack/registry/tree/resource"
+)
+
+// touchBucket save the unix time the resource is touched last time, the key is nodeID/resType/resID,
+// so touch only write one small value instead of rewriting the resource list.
+const touchBucket = "touch"
+
+func (t *Tree) initTouchBucket() error {
+	if err := t.cluster.CreateBucketIfNotExist([]byte(touchBucket)); err != nil {
+		t.logger.Errorf("tree init %s CreateBucketIfNotExist fail: %s", touchBucket, err.Error())
+		return err
+	}
+	return nil
+}
+
+func touchKey(nodeID, resType, resID string) []byte {
+	return []byte(nodeID + "/" + resType + "/" + resID)
+}
+
+// touchNodeID return the node ID of ns if the resource exist in it.
+// The resource is searched in the stored byte, the resource list is not unmarshaled.
+func (t *Tree) touchNodeID(ns, resType, resID string) (string, error) {
+	n, err := t.GetNodeByNS(ns)
+	if err != nil {
+		return "", err
+	}
+	if err := n.CheckResource(resType); err != nil {
+		return "", err
+	}
+	resByte, err := t.getByteFromStore(n.ID, resType)
+	if err != nil {
+		return "", err
+	}
+	if len(resByte) == 0 {
+		return "", resource.ErrNotFound
+	}
+	search := model.ResourceSearch{Id: resID}
+	if matched, err := search.IdSearch(resByte); err != nil {
+		return "", err
+	} else if len(matched) == 0 {
+		return "", resource.ErrNotFound
+	}
+	return n.ID, nil
+}
+
+// TouchResource set the touch time of the resource to now, the resource itself is not written.
+func (t *Tree) TouchResource(ns, resType, resID string) error {
+	nodeID, err := t.touchNodeID(ns, resType, resID)
+	if err != nil {
+		return err
+	}
+	now := strconv.FormatInt(time.Now().Unix(), 10)
+	if err := t.cluster.Update([]byte(touchBucket), touchKey(nodeID, resType, resID), []byte(now)); err != nil {
+		t.logger.Errorf("touch resource %s of ns %s fail: %s", resID, ns, err.Error())
+		return err
+	}
+	return nil
+}
+
+// TouchTime return the time the resource is touched last time, zero time if it is never touched.
+func (t *Tree) TouchTime(ns, resType, resID string) (time.Time, error) {
+	nodeID, err := t.touchNodeID(ns, resType, resID)
+	if err != nil {
+		return time.Time{}, err
+	}
+	v, err := t.cluster.View([]byte(touchBucket), touchKey(nodeID, resType, resID))
+	if err != nil || len(v) == 0 {
+		return time.Time{}, err
+	}
+	unix, err := strconv.ParseInt(string(v), 10, 64)
+	if err != nil {
+		t.logger.Errorf("invalid touch time of resource %s: %s", resID, string(v))
+		return time.Time{}, err
+	}
+	return time.Unix(unix, 0), nil
+}
@@ -5,14 +5,16 @@ import (
 	"sync"
 	"time"
 
-	"github.com/lodastack/log"
 	"github.com/lodastack/registry/common"
 	"github.com/lodastack/registry/config"
 	"github.com/lodastack/registry/model"
 	"github.com/lodastack/registry/tree/cluster"
+	"github.com/lodastack/registry/tree/codec"
 	"github.com/lodastack/registry/tree/machine"
 	"github.com/lodastack/registry/tree/node"
 	"github.com/lodastack/registry/tree/resource"
+
+	sm "github.com/lodastack/store/model"
 )
 
 var (
@@ -35,35 +37,91 @@ type Tree struct {
 	machine  machine.Inf
 	Mu       sync.RWMutex
 
+	// dashboardMu serialize ModifyDashboards in this process, not across registry nodes.
+	dashboardMu sync.Mutex
+
+	// retainMu serialize the write of tombstone and archive with their purge in this process,
+	// not across registry nodes.
+	retainMu sync.Mutex
+
+	// quotaMu serialize the quota check with the write of resources in this process,
+	// not across registry nodes.
+	quotaMu sync.Mutex
+
+	// codec encode the value saved by tree, e.g. dashboard.
+	codec codec.Codec
+
 	reports ReportInfo
-	logger  *log.Logger
+	logger  *model.Logger
+
+	// the machine not reported after staleAfter/downAfter is stale/down.
+	staleAfter time.Duration
+	downAfter  time.Duration
 }
 
 // NewTree return Tree obj.
 func NewTree(cluster cluster.Inf) (*Tree, error) {
+	for _, index := range config.C.CommonConf.Indexes {
+		// index is in form of resType.property, e.g. machine.sn.
+		if split := strings.SplitN(index, ".", 2); len(split) == 2 {
+			model.RegisterIndex(split[0], split[1])
+		}
+	}
 	nodeInf := node.NewNode(cluster)
-	logger := log.New(config.C.LogConf.Level, "tree", model.LogBackend)
-	r := resource.NewResource(cluster, nodeInf, logger)
+	logger := model.NewLogger(config.C.LogConf.Level, "tree")
+	c, err := codec.ByName(config.C.CommonConf.Codec)
+	if err != nil {
+		logger.Errorf("get codec %s fail: %s", config.C.CommonConf.Codec, err.Error())
+		return nil, err
+	}
 	t := Tree{
 		Nodes: &node.Node{
 			node.NodeProperty{ID: rootNodeID, Name: node.RootNode, Type: node.NonLeaf, MachineReg: node.NotMatchMachine},
 			[]*node.Node{}},
-		cluster:  cluster,
-		node:     nodeInf,
-		resource: r,
-		machine:  machine.NewMachine(nodeInf, r, logger),
-		Mu:       sync.RWMutex{},
-		logger:   logger,
-		reports:  ReportInfo{sync.RWMutex{}, make(map[string]model.Report)},
-	}
-	err := t.init()
+		cluster: cluster,
+		node:    nodeInf,
+		Mu:      sync.RWMutex{},
+		codec:   c,
+		logger:  logger,
+		reports: ReportInfo{ReportInfo: make(map[string]model.Report), lastSeen: make(map[string]time.Time)},
+	}
+	r := resource.NewCachedResource(cluster, nodeInf, logger, config.C.CommonConf.ResourceCache, t.writeWithQuota)
+	t.resource, t.machine = r, machine.NewMachine(nodeInf, r, logger)
+	t.SetHealthThreshold(time.Duration(config.C.CommonConf.HealthStale)*time.Second,
+		time.Duration(config.C.CommonConf.HealthDown)*time.Second)
+	err = t.init()
 	return &t, err
 }
 
+// SetCodec set the codec which the value is encoded by when it is written.
+// Value saved by other codec is still readable.
+func (t *Tree) SetCodec(c codec.Codec) {
+	t.codec = c
+}
+
 func (t *Tree) init() error {
 	if err := t.initNodeBucket(); err != nil {
 		return err
 	}
+	if err := t.initArchiveBucket(); err != nil {
+		return err
+	}
+	if err := t.initTombstoneBucket(); err != nil {
+		return err
+	}
+	if err := t.initQuotaBucket(); err != nil {
+		return err
+	}
+	if err := t.initDefaultsBucket(); err != nil {
+		return err
+	}
+	if err := t.initTouchBucket(); err != nil {
+		return err
+	}
+	if err := t.cluster.CreateBucketIfNotExist([]byte(resource.IndexBucket)); err != nil {
+		t.logger.Errorf("tree init %s CreateBucketIfNotExist fail: %s", resource.IndexBucket, err.Error())
+		return err
+	}
 	return t.initReportBucket()
 }
 
@@ -164,7 +222,27 @@ func (t *Tree) createBucketForNode(nodeID string) error {
 	return t.cluster.CreateBucket([]byte(nodeID))
 }
 
+// removeNodeResourceFromStore remove the bucket of the node, the secondary index and touch time of its resources.
 func (t *Tree) removeNodeResourceFromStore(nodeID string) error {
+	rows := []sm.Row{}
+	for _, bucket := range []string{resource.IndexBucket, touchBucket} {
+		kv, err := t.cluster.ViewPrefix([]byte(bucket), []byte(nodeID+"/"))
+		if err != nil {
+			t.logger.Errorf("view %s of node %s fail: %s", bucket, nodeID, err.Error())
+			return err
+		}
+		for k, v := range kv {
+			if len(v) != 0 {
+				rows = append(rows, sm.Row{Bucket: []byte(bucket), Key: []byte(k), Value: []byte{}})
+			}
+		}
+	}
+	if len(rows) != 0 {
+		if err := t.cluster.Batch(rows); err != nil {
+			t.logger.Errorf("remove index and touch time of node %s fail: %s", nodeID, err.Error())
+			return err
+		}
+	}
 	return t.cluster.RemoveBucket([]byte(nodeID))
 }
 
@@ -280,11 +358,14 @@ func (t *Tree) RemoveNode(ns string) error {
 		return err
 	}
 
+	// the resources of the node are removed with its bucket, so the quota usage is counted again.
+	t.quotaMu.Lock()
+	defer t.quotaMu.Unlock()
 	if err := t.removeNodeResourceFromStore(removeNodeID); err != nil {
 		t.logger.Errorf("remove node from store fail, parent ns: %s, delete ID: %s, error: %s", parentNs, removeNodeID, err.Error())
 		return err
 	}
-	return nil
+	return t.resetQuotaUsage()
 }
 
 // NewNode create a node, return a pointer which point to node, and it bucketId. Property is preserved.
@@ -341,6 +422,198 @@ func (t *Tree) NewNode(name, comment, parentNs string, nodeType int, machineRegi
 	return newNode.ID, t.initResourceOrTemplate(newNode, nodeType, parentNs, parentNodeID)
 }
 
+// NewNodeIfNotExist return the ID of the node if the node with the name and type already exist under parentNs,
+// otherwise create the node. Return whether the node is created.
+// Return ErrNodeAlreadyExist if the node exist with other type.
+func (t *Tree) NewNodeIfNotExist(name, comment, parentNs string, nodeType int, property ...string) (string, bool, error) {
+	ns := name + node.NodeDeli + parentNs
+	existID, err := t.existNodeID(ns, nodeType)
+	if err != nil || existID != "" {
+		return existID, false, err
+	}
+	id, err := t.NewNode(name, comment, parentNs, nodeType, property...)
+	if err == common.ErrNodeAlreadyExist {
+		// created by other request at the same time.
+		existID, err = t.existNodeID(ns, nodeType)
+		return existID, false, err
+	}
+	return id, err == nil, err
+}
+
+// EnsureNode return the ID of the node of ns if it exist with the type, otherwise create the node,
+// the missing parent ns are created as nonleaf node if createParents, otherwise return ErrNodeNotFound.
+// Return whether any node is created.
+func (t *Tree) EnsureNode(ns, comment string, nodeType int, createParents bool) (string, bool, error) {
+	split := strings.SplitN(ns, node.NodeDeli, 2)
+	if len(split) != 2 || split[0] == "" {
+		if ns == node.RootNode {
+			id, err := t.existNodeID(ns, node.NonLeaf)
+			return id, false, err
+		}
+		return "", false, common.ErrInvalidParam
+	}
+	name, parentNs := split[0], split[1]
+
+	parentCreated := false
+	parentID, err := t.existNodeID(parentNs, node.NonLeaf)
+	if err == common.ErrNodeAlreadyExist {
+		// the parent is leaf.
+		return "", false, common.ErrCreateNodeUnderLeaf
+	} else if err != nil {
+		return "", false, err
+	}
+	if parentID == "" {
+		if !createParents {
+			return "", false, common.ErrNodeNotFound
+		}
+		if _, parentCreated, err = t.EnsureNode(parentNs, "", node.NonLeaf, true); err != nil {
+			return "", false, err
+		}
+	}
+	id, created, err := t.NewNodeIfNotExist(name, comment, parentNs, nodeType)
+	return id, created || parentCreated, err
+}
+
+// existNodeID return the ID of the node if it exist with the type, return empty ID if the node not exist.
+func (t *Tree) existNodeID(ns string, nodeType int) (string, error) {
+	allNodes, err := t.AllNodes()
+	if err != nil {
+		return "", err
+	}
+	n, err := allNodes.GetByNS(ns)
+	if err != nil {
+		return "", nil
+	}
+	if n.Type != nodeType {
+		t.logger.Errorf("ns %s already exist with type %s", ns, node.TypeName(n.Type))
+		return "", common.ErrNodeAlreadyExist
+	}
+	return n.ID, nil
+}
+
+// NodeContent is the initial content of the node created by NewNodeWithContent.
+type NodeContent struct {
+	MachineReg string
+	// Resources is the resource type - resource list map,
+	// and is resource template map if the node is nonleaf.
+	Resources  map[string]model.ResourceList
+	Dashboards model.DashboardData
+}
+
+// NewNodeWithContent create a node and write its initial resources and dashboards in one batch,
+// with the tree of nodes and the template from parent node, so the node is created with all its content or not.
+// Only the bucket of the node is created before the batch, and is removed if the batch fail.
+func (t *Tree) NewNodeWithContent(name, comment, parentNs string, nodeType int, initial NodeContent) (string, error) {
+	if nodeType == node.Root {
+		return "", common.ErrInvalidParam
+	}
+	newNode := node.Node{
+		NodeProperty: node.NodeProperty{ID: common.GenUUID(), Name: name, Comment: comment, Type: nodeType, MachineReg: initial.MachineReg},
+		Children:     []*node.Node{},
+	}
+	if newNode.MachineReg == "" {
+		newNode.MachineReg = node.NotMatchMachine
+	}
+	if err := t.createBucketForNode(newNode.ID); err != nil {
+		t.logger.Errorf("NewNodeWithContent createNodeBucket fail, nodeid:%s, error: %s", newNode.ID, err.Error())
+		return "", err
+	}
+	if err := t.writeNodeWithContent(newNode, parentNs, initial); err != nil {
+		if err := t.removeNodeResourceFromStore(newNode.ID); err != nil {
+			t.logger.Errorf("remove bucket of node %s fail: %s", newNode.ID, err.Error())
+		}
+		return "", err
+	}
+	return newNode.ID, nil
+}
+
+// writeNodeWithContent write the tree with the new node, its template and content in one batch.
+func (t *Tree) writeNodeWithContent(newNode node.Node, parentNs string, initial NodeContent) error {
+	t.Mu.Lock()
+	defer t.Mu.Unlock()
+
+	// append the node to a copy of the tree, the tree from AllNodes is shared and should not be changed before commit.
+	nodes, err := t.copyNodes()
+	if err != nil {
+		return err
+	}
+	parent, err := nodes.GetByNS(parentNs)
+	if err != nil {
+		t.logger.Errorf("get parent id ns: %s, error: %v", parentNs, err)
+		return common.ErrGetParent
+	}
+	ns := newNode.Name + node.NodeDeli + parentNs
+	if nodes.Exist(ns) {
+		return common.ErrNodeAlreadyExist
+	}
+	if parent.IsLeaf() {
+		t.logger.Error("cannot create node under leaf, leaf node:", parentNs)
+		return common.ErrCreateNodeUnderLeaf
+	}
+	parent.Children = append(parent.Children, &newNode)
+	treeByte, err := nodes.MarshalJSON()
+	if err != nil {
+		return err
+	}
+
+	// the node and resource read from buffer include the new node.
+	buffer := cluster.NewBuffer(t.cluster)
+	nodeInf := node.NewNode(buffer)
+	if err := nodeInf.Save(treeByte); err != nil {
+		return err
+	}
+	if err := t.initTemplate(buffer, newNode, newNode.Type, parentNs, parent.ID); err != nil {
+		return err
+	}
+	// the quota is checked when the buffer is written.
+	r := resource.NewResource(buffer, nodeInf, t.logger, nil)
+	for resType, rl := range initial.Resources {
+		if err := r.SetResource(ns, resType, rl); err != nil {
+			t.logger.Errorf("set %s of new ns %s fail: %s", resType, ns, err.Error())
+			return err
+		}
+	}
+	if len(initial.Dashboards) != 0 {
+		if err := newNode.CheckResource(dashboardType); err != nil {
+			t.logger.Errorf("set dashboard of new ns %s fail: %s", ns, err.Error())
+			return err
+		}
+		resByte, err := codec.Encode(t.codec, initial.Dashboards)
+		if err != nil {
+			t.logger.Errorf("marshal dashboard fail: %s", err.Error())
+			return err
+		}
+		if err := buffer.Update([]byte(newNode.ID), []byte(dashboardType), resByte); err != nil {
+			return err
+		}
+	}
+
+	if err := t.writeWithQuota(t.cluster, buffer.Rows(), t.cluster.Batch); err != nil {
+		t.logger.Errorf("write new ns %s with content fail: %s", ns, err.Error())
+		return err
+	}
+	t.Nodes = nodes
+	return nil
+}
+
+// copyNodes return a copy of the tree which could be changed before it is saved,
+// the tree from AllNodes is shared.
+func (t *Tree) copyNodes() (*node.Node, error) {
+	allNodes, err := t.AllNodes()
+	if err != nil {
+		return nil, err
+	}
+	treeByte, err := allNodes.MarshalJSON()
+	if err != nil {
+		return nil, err
+	}
+	nodes := &node.Node{}
+	if err := nodes.UnmarshalJSON(treeByte); err != nil {
+		return nil, err
+	}
+	return nodes, nil
+}
+
 func (t *Tree) addNewNodeToTree(newNode node.Node, parentNs string, nodeType int) (string, error) {
 	var nodes, parent *node.Node
 	var err error
@@ -388,9 +661,22 @@ func (t *Tree) initResourceOrTemplate(newNode node.Node, nodeType int, parentNs,
 		}
 		return nil
 	}
+	buffer := cluster.NewBuffer(t.cluster)
+	if err := t.initTemplate(buffer, newNode, nodeType, parentNs, parentNodeID); err != nil {
+		return err
+	}
+	rows := buffer.Rows()
+	if len(rows) == 0 {
+		return nil
+	}
+	// the template of leaf is its resources, which is counted by quota.
+	return t.writeWithQuota(t.cluster, rows, t.cluster.Batch)
+}
 
+// initTemplate read the template of parent node from c and write it to the new node by c.
+func (t *Tree) initTemplate(c cluster.Inf, newNode node.Node, nodeType int, parentNs, parentNodeID string) error {
 	// Set the template of parent node to this new node.
-	templateRes, err := t.templateOfNode(parentNodeID)
+	templateRes, err := c.ViewPrefix([]byte(parentNodeID), []byte(template))
 	if err != nil {
 		return err
 	}
@@ -428,7 +714,7 @@ func (t *Tree) initResourceOrTemplate(newNode node.Node, nodeType int, parentNs,
 				return err
 			}
 		}
-		if err = t.setByteToStore(newNode.ID, resourceName, templateValue); err != nil {
+		if err = cluster.SetByte(c, newNode.ID, resourceName, templateValue); err != nil {
 			t.logger.Errorf("SetResourceByNs fail when newnode %s, error: %s", newNode.ID, err.Error())
 			return err
 		}
@@ -1,6 +1,7 @@
 package tree
 
 import (
+	"errors"
 	"os"
 	"sort"
 	"testing"
@@ -303,3 +304,287 @@ func TestRomoveNode(t *testing.T) {
 		t.Fatalf("delete ns have no machine fail, not match wich expect, error: %s", err.Error())
 	}
 }
+
+func TestNewNodeWithContent(t *testing.T) {
+	s := test_sample.MustNewStore(t)
+	defer os.RemoveAll(s.Path())
+
+	if err := s.Open(true); err != nil {
+		t.Fatalf("failed to open single-node store: %s", err.Error())
+	}
+	defer s.Close(true)
+	s.WaitForLeader(10 * time.Second)
+	tree, err := NewTree(s)
+	if err != nil {
+		t.Fatal("NewTree error")
+	}
+
+	resource1, _ := model.NewResourceList(resMap1)
+	content := NodeContent{
+		MachineReg: "^test1",
+		Resources:  map[string]model.ResourceList{"machine": *resource1},
+		Dashboards: model.DashboardData{{Title: "d0"}},
+	}
+	if _, err := tree.NewNodeWithContent("test1", "comment1", node.RootNode, node.Leaf, content); err != nil {
+		t.Fatalf("create leaf with content fail: %s", err.Error())
+	}
+	n, err := tree.GetNodeByNS("test1.loda")
+	if err != nil || n.MachineReg != "^test1" {
+		t.Fatalf("node not match with expect: %+v, %v", n, err)
+	}
+	if rl, err := tree.GetResourceList("test1.loda", "machine"); err != nil || len(*rl) != 2 {
+		t.Fatalf("resource of new node not match with expect: %v, %v", rl, err)
+	}
+	if dashboards, err := tree.GetDashboard("test1.loda"); err != nil || len(dashboards) != 1 {
+		t.Fatalf("dashboard of new node not match with expect: %v, %v", dashboards, err)
+	}
+
+	// nonleaf node could not have machine resource, the node is removed.
+	if _, err := tree.NewNodeWithContent("test2", "comment2", node.RootNode, node.NonLeaf, content); err == nil {
+		t.Fatal("create nonleaf with machine resource success, not match with expect")
+	}
+	if _, err := tree.GetNodeByNS("test2.loda"); err == nil {
+		t.Fatal("node is not removed after write content fail")
+	}
+}
+
+func TestNearestAncestorWithProperty(t *testing.T) {
+	s := test_sample.MustNewStore(t)
+	defer os.RemoveAll(s.Path())
+
+	if err := s.Open(true); err != nil {
+		t.Fatalf("failed to open single-node store: %s", err.Error())
+	}
+	defer s.Close(true)
+	s.WaitForLeader(10 * time.Second)
+	tree, err := NewTree(s)
+	if err != nil {
+		t.Fatal("NewTree error")
+	}
+	if _, err := tree.NewNode("a", "comment-a", node.RootNode, node.NonLeaf, "^a"); err != nil {
+		t.Fatalf("create nonleaf fail: %s", err.Error())
+	}
+	if _, err := tree.NewNode("b", "", "a."+node.RootNode, node.Leaf); err != nil {
+		t.Fatalf("create leaf fail: %s", err.Error())
+	}
+
+	for _, c := range []struct {
+		ns, key, expectNs, expectValue string
+	}{
+		{"b.a." + node.RootNode, "machinereg", "a." + node.RootNode, "^a"},
+		{"b.a." + node.RootNode, "comment", "a." + node.RootNode, "comment-a"},
+		{"a." + node.RootNode, "comment", "a." + node.RootNode, "comment-a"},
+		{node.PoolNode + "." + node.RootNode, "machinereg", "", ""},
+	} {
+		ns, value, err := tree.NearestAncestorWithProperty(c.ns, c.key)
+		if err != nil || ns != c.expectNs || value != c.expectValue {
+			t.Fatalf("nearest ancestor of %s with %s not match with expect: %s, %s, %v", c.ns, c.key, ns, value, err)
+		}
+	}
+	if _, _, err := tree.NearestAncestorWithProperty("notexist."+node.RootNode, "comment"); err != common.ErrNodeNotFound {
+		t.Fatalf("nearest ancestor of not exist ns not match with expect: %v", err)
+	}
+	if _, _, err := tree.NearestAncestorWithProperty("b.a."+node.RootNode, "unknown"); err == nil {
+		t.Fatalf("nearest ancestor with unknown property success, not match with expect")
+	}
+}
+
+func TestWalk(t *testing.T) {
+	s := test_sample.MustNewStore(t)
+	defer os.RemoveAll(s.Path())
+
+	if err := s.Open(true); err != nil {
+		t.Fatalf("failed to open single-node store: %s", err.Error())
+	}
+	defer s.Close(true)
+	s.WaitForLeader(10 * time.Second)
+	tree, err := NewTree(s)
+	if err != nil {
+		t.Fatal("NewTree error")
+	}
+	aNs := "a." + node.RootNode
+	if _, err := tree.NewNode("a", "", node.RootNode, node.NonLeaf); err != nil {
+		t.Fatalf("create nonleaf fail: %s", err.Error())
+	}
+	if _, err := tree.NewNode("b", "", aNs, node.NonLeaf); err != nil {
+		t.Fatalf("create nonleaf fail: %s", err.Error())
+	}
+	if _, err := tree.NewNode("c", "", "b."+aNs, node.Leaf); err != nil {
+		t.Fatalf("create leaf fail: %s", err.Error())
+	}
+
+	// case 1: pre-order with depth.
+	depths := map[string]int{}
+	order := []string{}
+	if err := tree.Walk(aNs, func(n *node.Node, depth int) error {
+		depths[n.Name] = depth
+		order = append(order, n.Name)
+		return nil
+	}); err != nil {
+		t.Fatalf("walk fail: %s", err.Error())
+	}
+	if len(order) != 4 || order[0] != "a" || depths["a"] != 0 || depths["b"] != 1 || depths["c"] != 2 {
+		t.Fatalf("walk not match with expect: %v, %v", order, depths)
+	}
+	for i, name := range order {
+		if name == "c" && (i == 0 || order[i-1] != "b") {
+			t.Fatalf("walk is not pre-order: %v", order)
+		}
+	}
+
+	// case 2: skip subtree.
+	order = []string{}
+	if err := tree.Walk(aNs, func(n *node.Node, depth int) error {
+		order = append(order, n.Name)
+		if n.Name == "b" {
+			return ErrSkipSubtree
+		}
+		return nil
+	}); err != nil || len(order) != 3 {
+		t.Fatalf("walk with skip not match with expect: %v, %v", order, err)
+	}
+
+	// case 3: stop by error.
+	errStop := errors.New("stop")
+	if err := tree.Walk(aNs, func(n *node.Node, depth int) error { return errStop }); err != errStop {
+		t.Fatalf("walk not stop by visit error: %v", err)
+	}
+	if err := tree.Walk("notexist."+node.RootNode, func(n *node.Node, depth int) error { return nil }); err != common.ErrNodeNotFound {
+		t.Fatalf("walk not exist ns not match with expect: %v", err)
+	}
+}
+
+func TestEnsureNode(t *testing.T) {
+	s := test_sample.MustNewStore(t)
+	defer os.RemoveAll(s.Path())
+
+	if err := s.Open(true); err != nil {
+		t.Fatalf("failed to open single-node store: %s", err.Error())
+	}
+	defer s.Close(true)
+	s.WaitForLeader(10 * time.Second)
+	tree, err := NewTree(s)
+	if err != nil {
+		t.Fatal("NewTree error")
+	}
+	ns := "leaf.mid.top." + node.RootNode
+
+	// case 1: parent not exist and not create parents.
+	if _, _, err := tree.EnsureNode(ns, "comment", node.Leaf, false); err != common.ErrNodeNotFound {
+		t.Fatalf("EnsureNode without parent not match with expect: %v", err)
+	}
+
+	// case 2: create the node and its parents.
+	id, created, err := tree.EnsureNode(ns, "comment", node.Leaf, true)
+	if err != nil || !created || id == "" {
+		t.Fatalf("EnsureNode not match with expect: %s, %v, %v", id, created, err)
+	}
+	for _, c := range []struct {
+		ns       string
+		nodeType int
+	}{
+		{"top." + node.RootNode, node.NonLeaf},
+		{"mid.top." + node.RootNode, node.NonLeaf},
+		{ns, node.Leaf},
+	} {
+		if n, err := tree.GetNodeByNS(c.ns); err != nil || n.Type != c.nodeType {
+			t.Fatalf("node %s not match with expect: %+v, %v", c.ns, n, err)
+		}
+	}
+
+	// case 3: the node already exist.
+	if existID, created, err := tree.EnsureNode(ns, "comment", node.Leaf, true); err != nil || created || existID != id {
+		t.Fatalf("EnsureNode exist node not match with expect: %s, %v, %v", existID, created, err)
+	}
+	if _, _, err := tree.EnsureNode(ns, "comment", node.NonLeaf, true); err != common.ErrNodeAlreadyExist {
+		t.Fatalf("EnsureNode exist node with other type not match with expect: %v", err)
+	}
+
+	// case 4: create the node under existing parent.
+	if _, created, err := tree.EnsureNode("leaf2.mid.top."+node.RootNode, "", node.Leaf, false); err != nil || !created {
+		t.Fatalf("EnsureNode under existing parent not match with expect: %v, %v", created, err)
+	}
+}
+
+func TestNewNodeIfNotExist(t *testing.T) {
+	s := test_sample.MustNewStore(t)
+	defer os.RemoveAll(s.Path())
+
+	if err := s.Open(true); err != nil {
+		t.Fatalf("failed to open single-node store: %s", err.Error())
+	}
+	defer s.Close(true)
+	s.WaitForLeader(10 * time.Second)
+	tree, err := NewTree(s)
+	if err != nil {
+		t.Fatal("NewTree error")
+	}
+
+	id, created, err := tree.NewNodeIfNotExist("test1", "comment1", node.RootNode, node.Leaf)
+	if err != nil || !created || id == "" {
+		t.Fatalf("create not exist node not match with expect: %s, %v, %v", id, created, err)
+	}
+	existID, created, err := tree.NewNodeIfNotExist("test1", "comment1", node.RootNode, node.Leaf)
+	if err != nil || created || existID != id {
+		t.Fatalf("create exist node not match with expect: %s, %v, %v", existID, created, err)
+	}
+	if _, _, err := tree.NewNodeIfNotExist("test1", "comment1", node.RootNode, node.NonLeaf); err != common.ErrNodeAlreadyExist {
+		t.Fatalf("create exist node with other type not match with expect: %v", err)
+	}
+}
+
+func TestMetrics(t *testing.T) {
+	s := test_sample.MustNewStore(t)
+	defer os.RemoveAll(s.Path())
+
+	if err := s.Open(true); err != nil {
+		t.Fatalf("failed to open single-node store: %s", err.Error())
+	}
+	defer s.Close(true)
+	s.WaitForLeader(10 * time.Second)
+	tree, err := NewTree(s)
+	if err != nil {
+		t.Fatal("NewTree error")
+	}
+	base, err := tree.Metrics()
+	if err != nil {
+		t.Fatalf("Metrics fail: %s", err.Error())
+	}
+
+	aNs := "a." + node.RootNode
+	if _, err := tree.NewNode("a", "", node.RootNode, node.NonLeaf); err != nil {
+		t.Fatalf("create nonleaf fail: %s", err.Error())
+	}
+	if _, err := tree.NewNode("b", "", aNs, node.NonLeaf); err != nil {
+		t.Fatalf("create nonleaf fail: %s", err.Error())
+	}
+	cNs := "c.b." + aNs
+	if _, err := tree.NewNode("c", "", "b."+aNs, node.Leaf); err != nil {
+		t.Fatalf("create leaf fail: %s", err.Error())
+	}
+	if err := tree.AppendResource(cNs, "machine",
+		model.NewResource(map[string]string{"hostname": "host1"}),
+		model.NewResource(map[string]string{"hostname": "host2"})); err != nil {
+		t.Fatalf("AppendResource fail: %s", err.Error())
+	}
+	if err := tree.SetDashboard(cNs, model.DashboardData{
+		{Title: "d0", Panels: []model.Panel{{Title: "p0"}, {Title: "p1"}}},
+		{Title: "d1"},
+	}); err != nil {
+		t.Fatalf("SetDashboard fail: %s", err.Error())
+	}
+
+	metrics, err := tree.Metrics()
+	if err != nil {
+		t.Fatalf("Metrics fail: %s", err.Error())
+	}
+	if metrics.Nodes != base.Nodes+3 || metrics.MaxDepth < 3 || metrics.Truncated {
+		t.Fatalf("Metrics of node not match with expect: %+v, base: %+v", metrics, base)
+	}
+	if metrics.Resources["machine"] != base.Resources["machine"]+2 {
+		t.Fatalf("Metrics of resource not match with expect: %+v, base: %+v", metrics, base)
+	}
+	if metrics.Dashboards != base.Dashboards+2 || metrics.Panels != base.Panels+2 {
+		t.Fatalf("Metrics of dashboard not match with expect: %+v, base: %+v", metrics, base)
+	}
+}
@@ -0,0 +1,113 @@
+package tree
+
+import (
+	"github.com/lodastack/registry/model"
+	"github.com/lodastack/registry/tree/cluster"
+	"github.com/lodastack/registry/tree/codec"
+	"github.com/lodastack/registry/tree/resource"
+)
+
+// TreeTx is the change of resources and dashboards in Tree.Tx.
+// The change is visible to the later read in the same TreeTx.
+type TreeTx interface {
+	// GetResourceList return the resource list of the ns, include the change in the tx.
+	GetResourceList(ns, resType string) (*model.ResourceList, error)
+
+	// SetResource set the resource list to the ns.
+	SetResource(ns, resType string, rl model.ResourceList) error
+
+	// AppendResource append resources to the ns.
+	AppendResource(ns, resType string, appendRes ...model.Resource) error
+
+	// UpdateResource update one resource by updateMap.
+	UpdateResource(ns, resType, resID string, updateMap map[string]string) error
+
+	// RemoveResource remove resources from the ns.
+	RemoveResource(ns, resType string, resID ...string) error
+
+	// MoveResourceKeepID move the resources to an other ns and keep their ID.
+	MoveResourceKeepID(oldNs, newNs, resType string, resID ...string) ([]model.Resource, error)
+
+	// SetDashboard set the dashboards to the ns.
+	SetDashboard(ns string, dashboards model.DashboardData) error
+
+	// ModifyDashboards make multiple changes to the dashboards of the ns.
+	ModifyDashboards(ns string, fn func(*DashboardEditor) error) error
+}
+
+type treeTx struct {
+	t      *Tree
+	buffer *cluster.Buffer
+	resource.Inf
+}
+
+// Tx call fn with a TreeTx, the changes made by fn are written in one batch after fn return,
+// so the change across multiple ns is applied all or nothing.
+// Nothing is written if fn return error.
+// NOTE: the tx is not isolated from the change made outside it before commit.
+func (t *Tree) Tx(fn func(TreeTx) error) error {
+	return t.tx(func(tx *treeTx) error { return fn(tx) })
+}
+
+// tx is Tx which fn could also write the bucket other than node by tx.buffer.
+func (t *Tree) tx(fn func(*treeTx) error) error {
+	buffer := cluster.NewBuffer(t.cluster)
+	tx := &treeTx{t: t, buffer: buffer, Inf: resource.NewResource(buffer, t.node, t.logger, nil)}
+	if err := fn(tx); err != nil {
+		t.logger.Errorf("tx fail, nothing is committed: %s", err.Error())
+		return err
+	}
+	rows := buffer.Rows()
+	if len(rows) == 0 {
+		return nil
+	}
+	// the quota is checked by all the change of the tx.
+	if err := t.writeWithQuota(t.cluster, rows, t.cluster.Batch); err != nil {
+		t.logger.Errorf("commit tx fail: %s", err.Error())
+		return err
+	}
+	return nil
+}
+
+func (tx *treeTx) SetDashboard(ns string, dashboards model.DashboardData) error {
+	nodeID, err := tx.t.getNodeIDAllowDashboard(ns)
+	if err != nil {
+		return err
+	}
+	resByte, err := codec.Encode(tx.t.codec, dashboards)
+	if err != nil {
+		tx.t.logger.Errorf("marshal dashboard fail: %s", err.Error())
+		return err
+	}
+	return tx.buffer.Update([]byte(nodeID), []byte(dashboardType), resByte)
+}
+
+// getDashboards return the dashboards of the ns, include the change in the tx.
+func (tx *treeTx) getDashboards(ns string) (model.DashboardData, error) {
+	nodeID, err := tx.t.getNodeIDByNS(ns)
+	if err != nil {
+		return nil, err
+	}
+	resByte, err := tx.buffer.View([]byte(nodeID), []byte(dashboardType))
+	if err != nil || len(resByte) == 0 {
+		return nil, err
+	}
+	var dashboards model.DashboardData
+	if err := codec.Decode(resByte, &dashboards); err != nil {
+		tx.t.logger.Errorf("unmarshal dashboard of ns %s fail: %s", ns, err.Error())
+		return nil, err
+	}
+	return dashboards, nil
+}
+
+func (tx *treeTx) ModifyDashboards(ns string, fn func(*DashboardEditor) error) error {
+	dashboards, err := tx.getDashboards(ns)
+	if err != nil {
+		return err
+	}
+	editor := &DashboardEditor{Data: dashboards}
+	if err := fn(editor); err != nil {
+		return err
+	}
+	return tx.SetDashboard(ns, editor.Data)
+}
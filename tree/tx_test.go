@@ -0,0 +1,75 @@
+package tree
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/lodastack/registry/model"
+	"github.com/lodastack/registry/tree/node"
+	"github.com/lodastack/registry/tree/test_sample"
+)
+
+func TestTx(t *testing.T) {
+	s := test_sample.MustNewStore(t)
+	defer os.RemoveAll(s.Path())
+	if err := s.Open(true); err != nil {
+		t.Fatalf("failed to open single-node store: %s", err.Error())
+	}
+	defer s.Close(true)
+	s.WaitForLeader(10 * time.Second)
+	tree, _ := NewTree(s)
+
+	for _, name := range []string{"txLeaf1", "txLeaf2"} {
+		if _, err := tree.NewNode(name, "comment", node.RootNode, node.Leaf); err != nil {
+			t.Fatalf("create leaf fail: %s", err.Error())
+		}
+	}
+	rl, _ := model.NewResourceList(resMap1)
+	if err := tree.SetResource("txLeaf1.loda", "machine", *rl); err != nil {
+		t.Fatalf("set resource fail: %s", err.Error())
+	}
+	moveID, _ := (*rl)[0].ID()
+
+	// nothing is committed if fn return error.
+	errAbort := errors.New("abort")
+	err := tree.Tx(func(tx TreeTx) error {
+		if _, err := tx.MoveResourceKeepID("txLeaf1.loda", "txLeaf2.loda", "machine", moveID); err != nil {
+			return err
+		}
+		return errAbort
+	})
+	if err != errAbort {
+		t.Fatalf("tx not return the error of fn: %v", err)
+	}
+	if l, err := tree.GetResourceList("txLeaf1.loda", "machine"); err != nil || len(*l) != 2 {
+		t.Fatalf("resource changed by aborted tx: %v, %v", l, err)
+	}
+
+	err = tree.Tx(func(tx TreeTx) error {
+		if _, err := tx.MoveResourceKeepID("txLeaf1.loda", "txLeaf2.loda", "machine", moveID); err != nil {
+			return err
+		}
+		if err := tx.UpdateResource("txLeaf2.loda", "machine", moveID, map[string]string{"status": "moved"}); err != nil {
+			return err
+		}
+		return tx.ModifyDashboards("txLeaf2.loda", func(e *DashboardEditor) error {
+			e.AddDashboard(model.Dashboard{Title: "moved"})
+			return nil
+		})
+	})
+	if err != nil {
+		t.Fatalf("tx fail: %s", err.Error())
+	}
+	if l, err := tree.GetResourceList("txLeaf1.loda", "machine"); err != nil || len(*l) != 1 {
+		t.Fatalf("resource of old ns not match with expect: %v, %v", l, err)
+	}
+	moved, err := tree.GetResource("txLeaf2.loda", "machine", moveID)
+	if err != nil || len(moved) != 1 || moved[0]["status"] != "moved" {
+		t.Fatalf("resource of new ns not match with expect: %v, %v", moved, err)
+	}
+	if dashboards, err := tree.GetDashboard("txLeaf2.loda"); err != nil || len(dashboards) != 1 {
+		t.Fatalf("dashboard not match with expect: %v, %v", dashboards, err)
+	}
+}
@@ -0,0 +1,186 @@
+package tree
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/lodastack/registry/model"
+	"github.com/lodastack/registry/tree/node"
+)
+
+// ValidationError is a problem of the item at Index of a batch.
+// Field is the part of the item which has the problem, e.g. "panel 0 target 1" of a dashboard,
+// it is empty if the problem is of the whole item.
+type ValidationError struct {
+	Index int    `json:"index"`
+	Field string `json:"field,omitempty"`
+	Msg   string `json:"msg"`
+}
+
+// ValidationErrors is all the problems found in a batch.
+type ValidationErrors []ValidationError
+
+func (e ValidationErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, problem := range e {
+		if problem.Field != "" {
+			msgs[i] = fmt.Sprintf("[%d] %s: %s", problem.Index, problem.Field, problem.Msg)
+		} else {
+			msgs[i] = fmt.Sprintf("[%d] %s", problem.Index, problem.Msg)
+		}
+	}
+	return fmt.Sprintf("%d validation errors: %s", len(e), strings.Join(msgs, "; "))
+}
+
+func (e *ValidationErrors) add(index int, msg string) {
+	*e = append(*e, ValidationError{Index: index, Msg: msg})
+}
+
+func (e *ValidationErrors) addField(index int, field, msg string) {
+	*e = append(*e, ValidationError{Index: index, Field: field, Msg: msg})
+}
+
+// ValidateDashboards check the dashboards in json without saving them, return the problems found,
+// the Index of the problem is the index of dashboard.
+// Return error only if the data is not dashboards.
+func (t *Tree) ValidateDashboards(data []byte) (ValidationErrors, error) {
+	var dashboards model.DashboardData
+	if err := json.Unmarshal(data, &dashboards); err != nil {
+		return nil, err
+	}
+	return validateDashboards(dashboards), nil
+}
+
+func validateDashboards(dashboards model.DashboardData) ValidationErrors {
+	errs := ValidationErrors{}
+	titles := map[string]bool{}
+	for i, d := range dashboards {
+		if d.Title == "" {
+			errs.add(i, "dashboard has no title")
+		} else if titles[d.Title] {
+			errs.add(i, "dashboard title "+d.Title+" is duplicated")
+		}
+		titles[d.Title] = true
+
+		for j, p := range d.Panels {
+			panel := fmt.Sprintf("panel %d", j)
+			if p.GraphType == "" {
+				errs.addField(i, panel, "panel has no graph type")
+			}
+			if len(p.Targets) == 0 {
+				errs.addField(i, panel, "panel has no target")
+			}
+			for k, target := range p.Targets {
+				field := fmt.Sprintf("%s target %d", panel, k)
+				if target.Ns == "" {
+					errs.addField(i, field, "target has no ns")
+				}
+				if target.Measurement == "" {
+					errs.addField(i, field, "target has no measurement")
+				}
+			}
+		}
+	}
+	return errs
+}
+
+// ValidateMachines check all the machines to register without saving them, return the problems found.
+// The machines are checked after the defaults of machine are set, as RegisterMachine does.
+// The machine should have hostname unique in the batch, the ip should not be empty if set,
+// and all the ns the machine match should be leaf.
+// Return error only if fail to read the tree.
+func (t *Tree) ValidateMachines(machines ...model.Resource) (ValidationErrors, error) {
+	machines, err := t.withDefaults(model.Machine, machines...)
+	if err != nil {
+		return nil, err
+	}
+	allNodes, err := t.AllNodes()
+	if err != nil {
+		return nil, err
+	}
+
+	errs := ValidationErrors{}
+	hostnames := map[string]bool{}
+	for i, m := range machines {
+		if ip, ok := m.ReadProperty(model.IpProp); ok && ip == "" {
+			errs.add(i, "machine has empty ip")
+		}
+		hostname, _ := m.ReadProperty(model.HostnameProp)
+		if hostname == "" {
+			errs.add(i, "machine has no hostname")
+			continue
+		}
+		if hostnames[hostname] {
+			errs.add(i, "machine hostname "+hostname+" is duplicated")
+		}
+		hostnames[hostname] = true
+
+		nsList, err := t.machine.MatchNs(hostname)
+		if err != nil {
+			return nil, err
+		}
+		for _, ns := range nsList {
+			n, err := allNodes.GetByNS(ns)
+			if err != nil {
+				errs.add(i, "machine match ns "+ns+" which not exist")
+			} else if err := n.CheckType(node.Leaf); err != nil {
+				errs.add(i, err.Error())
+			}
+		}
+	}
+	return errs, nil
+}
+
+// ValidateResources check all the resources to append to the ns without saving them, return the problems found.
+// The resources are checked after the defaults of resource type are set, as AppendResource does.
+// The resource should have property other than ID and a pk property unique in the batch and the ns,
+// the ID set should be unique in the batch and the ns.
+// Return error only if fail to read the resources of the ns.
+func (t *Tree) ValidateResources(ns, resType string, rs ...model.Resource) (ValidationErrors, error) {
+	rs, err := t.withDefaults(resType, rs...)
+	if err != nil {
+		return nil, err
+	}
+	rl, err := t.GetResourceList(ns, resType)
+	if err != nil {
+		return nil, err
+	}
+	pk := model.PkProperty[strings.TrimPrefix(resType, model.TemplatePrefix)]
+	ids, pkValues := map[string]bool{}, map[string]bool{}
+	if rl != nil {
+		for _, r := range *rl {
+			id, _ := r.ID()
+			ids[id] = true
+			if pk != "" {
+				pkValue, _ := r.ReadProperty(pk)
+				pkValues[pkValue] = true
+			}
+		}
+	}
+
+	errs := ValidationErrors{}
+	for i, r := range rs {
+		id, _ := r.ID()
+		if len(r) == 0 || (id != "" && len(r) == 1) {
+			errs.add(i, "resource only have id")
+		}
+		if id != "" {
+			if ids[id] {
+				errs.add(i, "resource id "+id+" already exist")
+			}
+			ids[id] = true
+		}
+		if pk == "" {
+			continue
+		}
+		if pkValue, _ := r.ReadProperty(pk); pkValue == "" {
+			errs.add(i, "resource has no pk property "+pk)
+		} else if pkValues[pkValue] {
+			errs.add(i, "resource "+pk+" "+pkValue+" already exist")
+		} else {
+			pkValues[pkValue] = true
+		}
+	}
+	return errs, nil
+}